@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alex-leonhardt/kube-smoketest/pkg/smoketests"
+	"github.com/golang/glog"
+	"k8s.io/client-go/kubernetes"
+)
+
+// StaleObjects are the objects kube-smoketest left behind in a cluster
+type StaleObjects struct {
+	Cluster Cluster
+	Client  kubernetes.Interface
+	Objects []smoketests.Stale
+}
+
+// WriteStale writes a table of the stale objects of all clusters
+func WriteStale(w io.Writer, stale []StaleObjects, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CLUSTER\tKIND\tNAME\tRUN ID\tAGE")
+	for _, s := range stale {
+		for _, obj := range s.Objects {
+			runID := obj.RunID
+			if runID == "" {
+				runID = "-"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%v\n", s.Cluster.Name, obj.Kind(), obj.Name(), runID, now.Sub(obj.Created).Round(time.Second))
+		}
+	}
+	return tw.Flush()
+}
+
+// Clean deletes what kube-smoketest left behind in one or many clusters, e.g. namespaces kept with -debug and the
+// volumes they retained, and waits until it's gone
+func Clean(base KubeConfigFlags, timeout time.Duration, args []string) error {
+	fs := flag.NewFlagSet("clean", flag.ExitOnError)
+	olderThan := fs.Duration("older-than", 0, "only delete what was created more than this long ago, e.g. 24h")
+	runID := fs.String("run-id", "", "only delete what the run with this id created")
+	selector := fs.String("selector", "", "only delete what matches this label selector too, e.g. kube-smoketest/user=jane")
+	dryRun := fs.Bool("dry-run", false, "only show what would be deleted")
+	contexts := fs.String("contexts", "", "comma separated list of kubeconfig contexts to clean, defaults to the current context")
+	glob := fs.String("kubeconfigs", "", "glob of kubeconfig files to clean, using each file's current context, e.g. 'clusters/*.yaml'")
+	fs.DurationVar(&timeout, "timeout", timeout, "max. duration of deleting everything in a single cluster, defaults to timeouts.cleanup of the config")
+	fs.Parse(args)
+
+	contextList := []string{}
+	for _, c := range strings.Split(*contexts, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			contextList = append(contextList, c)
+		}
+	}
+	clusters, err := FanoutClusters(base, contextList, *glob)
+	if err != nil {
+		return err
+	}
+	if len(clusters) < 1 {
+		clusters = []Cluster{{Name: "current", Flags: base}}
+	}
+
+	filter := smoketests.StaleFilter{OlderThan: *olderThan, RunID: *runID, Selector: *selector}
+
+	ctx, stop := withSignals(context.Background(), true)
+	defer stop()
+
+	stale := []StaleObjects{}
+	found := 0
+	for _, cluster := range clusters {
+		config, err := cluster.Flags.LoadConfig()
+		if err != nil {
+			return fmt.Errorf("cluster %q: %v", cluster.Name, err)
+		}
+		client, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return fmt.Errorf("cluster %q: %v", cluster.Name, err)
+		}
+		objects, err := smoketests.FindStale(ctx, client, filter)
+		if err != nil {
+			return fmt.Errorf("cluster %q: %v", cluster.Name, err)
+		}
+		stale = append(stale, StaleObjects{Cluster: cluster, Client: client, Objects: objects})
+		found += len(objects)
+	}
+
+	if found < 1 {
+		glog.Infoln("nothing to clean up")
+		return nil
+	}
+	if err := WriteStale(os.Stdout, stale, time.Now()); err != nil {
+		return err
+	}
+	if *dryRun {
+		glog.Infof("dry run, not deleting %d objects", found)
+		return nil
+	}
+
+	failed := 0
+	for _, s := range stale {
+		if len(s.Objects) < 1 {
+			continue
+		}
+		clusterCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := smoketests.RemoveStale(clusterCtx, s.Objects)
+		cancel()
+		if err != nil {
+			glog.Errorf("\t🔴 cluster %q: %v", s.Cluster.Name, err)
+			failed++
+			continue
+		}
+		glog.Infof("\t✅ cluster %q: deleted %d objects", s.Cluster.Name, len(s.Objects))
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to clean up %d of %d clusters", failed, len(clusters))
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alex-leonhardt/kube-smoketest/pkg/smoketests"
+)
+
+func TestWriteStale(t *testing.T) {
+	now := time.Now()
+	stale := []StaleObjects{
+		{Cluster: Cluster{Name: "prod-eu"}, Objects: []smoketests.Stale{{RunID: "0123456789ab", Created: now.Add(-90 * time.Minute)}}},
+		{Cluster: Cluster{Name: "prod-us"}},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WriteStale(buf, stale, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a header and 1 object, got:\n%s", buf.String())
+	}
+	if fields := strings.Fields(lines[1]); fields[0] != "prod-eu" || fields[len(fields)-2] != "0123456789ab" || fields[len(fields)-1] != "1h30m0s" {
+		t.Errorf("unexpected row: %q", lines[1])
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alex-leonhardt/kube-smoketest/pkg/config"
+	"github.com/alex-leonhardt/kube-smoketest/pkg/smoketests"
+)
+
+// LoadConfig loads the config at path, or the defaults when path is empty, validated against the registered checks
+func LoadConfig(path string) (*config.Config, error) {
+	names := []string{}
+	for _, check := range smoketests.Checks() {
+		names = append(names, check.Name())
+	}
+	return config.Load(path, names)
+}
+
+// WriteDefaults writes the default config as YAML, preceded by comments listing the environment variables
+// overriding it
+func WriteDefaults(w io.Writer) error {
+	b, err := config.Default().YAML()
+	if err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, "# kube-smoketest default config, use with: kube-smoketest -config <file>")
+	fmt.Fprintln(buf, "#")
+	fmt.Fprintln(buf, "# these environment variables override the config:")
+	for _, env := range config.Env() {
+		fmt.Fprintf(buf, "#   %s: %s\n", env[0], env[1])
+	}
+	fmt.Fprintln(buf, "#")
+	fmt.Fprintln(buf, "# checks can be disabled by name, e.g.")
+	fmt.Fprintln(buf, "#   checks:")
+	fmt.Fprintf(buf, "#     %q:\n", smoketests.CheckSecret)
+	fmt.Fprintln(buf, "#       enabled: false")
+	buf.Write(b)
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// ConfigCommand runs the config command, only print-defaults for now
+func ConfigCommand(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("missing config command, use: print-defaults")
+	}
+
+	switch args[0] {
+	case "print-defaults":
+		return WriteDefaults(os.Stdout)
+	default:
+		return fmt.Errorf("unknown config command %q, use: print-defaults", args[0])
+	}
+}
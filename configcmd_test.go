@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteDefaultsLoads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kube-smoketest")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	buf := &bytes.Buffer{}
+	if err := WriteDefaults(buf); err != nil {
+		t.Fatalf("failed to write defaults: %v", err)
+	}
+
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if _, err := LoadConfig(path); err != nil {
+		t.Fatalf("expected the printed defaults to load, got: %v", err)
+	}
+}
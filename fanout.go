@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alex-leonhardt/kube-smoketest/pkg/smoketests"
+	"github.com/golang/glog"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Cluster is a cluster the fanout command runs the smoketests against
+type Cluster struct {
+	// Name identifies the cluster in the matrix, the context's name or the kubeconfig file's name
+	Name  string
+	Flags KubeConfigFlags
+}
+
+// ClusterReport is the outcome of running the smoketests against a cluster
+type ClusterReport struct {
+	Cluster Cluster
+	Report  *smoketests.Report
+	// Err is set when the smoketests could not be run against the cluster at all
+	Err error
+}
+
+// ExitCode is the cluster's exit code, as if the smoketests were run against the cluster alone
+func (r ClusterReport) ExitCode() int {
+	if r.Err != nil {
+		return 1
+	}
+	return r.Report.ExitCode()
+}
+
+// FanoutClusters returns the clusters to run against, one per context, and one per kubeconfig file matching
+// glob using the file's current context
+func FanoutClusters(base KubeConfigFlags, contexts []string, glob string) ([]Cluster, error) {
+	clusters := []Cluster{}
+
+	for _, context := range contexts {
+		flags := base
+		flags.Overrides.CurrentContext = context
+		clusters = append(clusters, Cluster{Name: context, Flags: flags})
+	}
+
+	if glob != "" {
+		paths, err := filepath.Glob(glob)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kubeconfig glob %q: %v", glob, err)
+		}
+		if len(paths) < 1 {
+			return nil, fmt.Errorf("no kubeconfig files match %q", glob)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			flags := base
+			flags.Kubeconfig = path
+			clusters = append(clusters, Cluster{Name: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), Flags: flags})
+		}
+	}
+
+	return clusters, nil
+}
+
+// RunFanout runs the smoketests against all clusters, running against at most workers clusters at the same time;
+// the reports are returned in the order of clusters
+func RunFanout(ctx context.Context, clusters []Cluster, workers int, timeout time.Duration, opts SuiteOptions) []ClusterReport {
+	if workers < 1 {
+		workers = 1
+	}
+
+	reports := make([]ClusterReport, len(clusters))
+	sem := make(chan struct{}, workers)
+	wg := sync.WaitGroup{}
+
+	for i, cluster := range clusters {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int, cluster Cluster) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if ctx.Err() != nil {
+				reports[i] = ClusterReport{Cluster: cluster, Err: fmt.Errorf("not started: %w", ctx.Err())}
+				return
+			}
+
+			glog.Infof("running smoketests against cluster %q", cluster.Name)
+			report, err := runCluster(ctx, cluster, timeout, opts)
+			reports[i] = ClusterReport{Cluster: cluster, Report: report, Err: err}
+			if err != nil {
+				glog.Errorf("\t🔴 cluster %q: %v", cluster.Name, err)
+			}
+		}(i, cluster)
+	}
+	wg.Wait()
+
+	return reports
+}
+
+// runCluster runs the smoketests against a single cluster
+func runCluster(ctx context.Context, cluster Cluster, timeout time.Duration, opts SuiteOptions) (*smoketests.Report, error) {
+	config, err := cluster.Flags.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	dyn, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// the runs against all clusters log at the same time
+	opts.Cluster = cluster.Name
+	return RunSuite(ctx, client, dyn, config.Host, opts)
+}
+
+// WriteMatrix writes a cluster x check table of the check's statuses, and each cluster's exit code; there's a
+// column for every check that is part of any report, checks that did not run on any cluster are left out
+func WriteMatrix(w io.Writer, reports []ClusterReport) error {
+	names := matrixColumns(reports)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "CLUSTER\t%s\tEXIT\n", strings.ToUpper(strings.Join(names, "\t")))
+
+	for _, r := range reports {
+		cells := []string{}
+		for _, name := range names {
+			status := "-"
+			if r.Report != nil {
+				if result := r.Report.Result(name); result != nil {
+					status = string(result.Status)
+				}
+			}
+			cells = append(cells, status)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\n", r.Cluster.Name, strings.Join(cells, "\t"), r.ExitCode())
+	}
+
+	return tw.Flush()
+}
+
+// matrixColumns returns the names of the checks that are part of any of reports, registered checks in the order
+// they were registered, followed by the others, e.g. the teardown, in the order they were reported
+func matrixColumns(reports []ClusterReport) []string {
+	reported := map[string]bool{}
+	others := []string{}
+	for _, r := range reports {
+		if r.Report == nil {
+			continue
+		}
+		for _, result := range r.Report.Results {
+			if !reported[result.Name] {
+				reported[result.Name] = true
+				others = append(others, result.Name)
+			}
+		}
+	}
+
+	names := []string{}
+	registered := map[string]bool{}
+	for _, check := range smoketests.Checks() {
+		registered[check.Name()] = true
+		if reported[check.Name()] {
+			names = append(names, check.Name())
+		}
+	}
+	for _, name := range others {
+		if !registered[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Fanout runs the smoketests against many clusters concurrently, prints the cluster x check matrix, and
+// returns the number of clusters that did not pass; it only returns an error if the smoketests cannot be
+// run at all
+func Fanout(base KubeConfigFlags, opts SuiteOptions, args []string) (int, error) {
+	fs := flag.NewFlagSet("fanout", flag.ExitOnError)
+	contexts := fs.String("contexts", "", "comma separated list of kubeconfig contexts to run against")
+	glob := fs.String("kubeconfigs", "", "glob of kubeconfig files to run against, using each file's current context, e.g. 'clusters/*.yaml'")
+	workers := fs.Int("workers", 4, "max. number of clusters to run against at the same time")
+	timeout := fs.Duration("timeout", opts.Timeout, "max. duration of the run against a single cluster, defaults to timeouts.run of the config")
+	fs.Parse(args)
+
+	if err := ValidateTimeout("-timeout", *timeout, opts); err != nil {
+		return 0, err
+	}
+
+	contextList := []string{}
+	for _, c := range strings.Split(*contexts, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			contextList = append(contextList, c)
+		}
+	}
+
+	clusters, err := FanoutClusters(base, contextList, *glob)
+	if err != nil {
+		return 0, err
+	}
+	if len(clusters) < 1 {
+		return 0, fmt.Errorf("no clusters to run against, use -contexts and/or -kubeconfigs")
+	}
+
+	ctx, stop := withSignals(context.Background(), false)
+	reports := RunFanout(ctx, clusters, *workers, *timeout, opts)
+	stop()
+
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, strings.Repeat("-", 20), "RESULT", strings.Repeat("-", 20))
+	fmt.Fprintln(os.Stderr, "")
+
+	if err := WriteMatrix(os.Stdout, reports); err != nil {
+		return 0, err
+	}
+
+	failed := 0
+	for _, r := range reports {
+		if r.ExitCode() != 0 {
+			failed++
+		}
+	}
+	if failed > 0 {
+		glog.Errorf("\t🔴 FAILED: %d of %d clusters did not pass", failed, len(reports))
+	} else {
+		glog.Infof("\t✅ SUCCESS: all tests passed on all %d clusters", len(reports))
+	}
+	return failed, nil
+}
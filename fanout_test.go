@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alex-leonhardt/kube-smoketest/pkg/smoketests"
+)
+
+func TestFanoutClusters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kube-smoketest")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	writeKubeconfigs(t, dir, "prod-eu", "prod-us")
+
+	clusters, err := FanoutClusters(KubeConfigFlags{}, []string{"staging"}, filepath.Join(dir, "prod-*.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	names := []string{}
+	for _, c := range clusters {
+		names = append(names, c.Name)
+	}
+	if strings.Join(names, ",") != "staging,prod-eu,prod-us" {
+		t.Fatalf("unexpected clusters: %v", names)
+	}
+	if clusters[0].Flags.Overrides.CurrentContext != "staging" {
+		t.Errorf("expected context override staging, got: %q", clusters[0].Flags.Overrides.CurrentContext)
+	}
+	if clusters[2].Flags.Kubeconfig != filepath.Join(dir, "prod-us.yaml") {
+		t.Errorf("expected kubeconfig prod-us.yaml, got: %q", clusters[2].Flags.Kubeconfig)
+	}
+
+	if _, err := FanoutClusters(KubeConfigFlags{}, nil, filepath.Join(dir, "missing-*.yaml")); err == nil {
+		t.Error("expected an error when no kubeconfig matches the glob")
+	}
+}
+
+func TestWriteMatrix(t *testing.T) {
+	// as if run with -tags network, results are added in the order the checks finished
+	report := &smoketests.Report{}
+	for _, name := range []string{smoketests.CheckComponentStatus, smoketests.CheckNamespace, smoketests.CheckDeployment, smoketests.CheckNodePortService, smoketests.CheckService} {
+		report.Add(&smoketests.Result{Name: name, Status: smoketests.StatusPass})
+	}
+	report.Result(smoketests.CheckService).Status = smoketests.StatusFail
+	report.Add(&smoketests.Result{Name: smoketests.CheckDeleteNamespace, Status: smoketests.StatusPass})
+
+	reports := []ClusterReport{
+		{Cluster: Cluster{Name: "prod-eu"}, Report: report},
+		{Cluster: Cluster{Name: "prod-us"}, Err: errors.New("context deadline exceeded")},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WriteMatrix(buf, reports); err != nil {
+		t.Fatalf("failed to write matrix: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected a header and 2 clusters, got:\n%s", buf.String())
+	}
+	header := strings.Join([]string{"CLUSTER", "COMPONENT STATUSES", "CREATE NAMESPACE", "DEPLOYMENT", "SERVICE", "NODEPORT SERVICE", "DELETE NAMESPACE", "EXIT"}, " ")
+	if got := strings.Join(strings.Fields(lines[0]), " "); got != header {
+		t.Errorf("expected only the checks that ran, in the order they were registered, got: %q", got)
+	}
+	if fields := strings.Fields(lines[1]); fields[0] != "prod-eu" || fields[len(fields)-1] != "1" || !strings.Contains(lines[1], "fail") {
+		t.Errorf("unexpected row for prod-eu: %q", lines[1])
+	}
+	if fields := strings.Fields(lines[2]); fields[1] != "-" || fields[len(fields)-1] != "1" {
+		t.Errorf("unexpected row for prod-us: %q", lines[2])
+	}
+}
+
+func TestFanoutTimeoutTooShort(t *testing.T) {
+	opts := SuiteOptions{Concurrency: 1, Timeout: time.Minute}
+	_, err := Fanout(KubeConfigFlags{}, opts, []string{"-contexts", "prod-eu", "-timeout", "1m"})
+	if err == nil || !strings.Contains(err.Error(), "-timeout must be greater than") {
+		t.Fatalf("expected the run timeout to be rejected, got: %v", err)
+	}
+}
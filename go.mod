@@ -12,10 +12,12 @@ require (
 	github.com/hashicorp/go-multierror v1.1.0
 	github.com/jpillora/backoff v1.0.0
 	github.com/kr/pretty v0.1.0
+	github.com/prometheus/client_golang v1.7.1
 	go.etcd.io/etcd v3.3.20+incompatible
 	go.uber.org/zap v1.15.0
 	google.golang.org/grpc v1.19.0
 	k8s.io/api v0.18.2
 	k8s.io/apimachinery v0.18.2
 	k8s.io/client-go v0.18.2
+	sigs.k8s.io/yaml v1.2.0
 )
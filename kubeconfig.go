@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/golang/glog"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// stringSlice is a flag.Value collecting the values of a flag given multiple times
+type stringSlice []string
+
+func (s *stringSlice) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSlice) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// KubeConfigFlags are the standard client-go flags selecting the kube config, cluster, user and context
+type KubeConfigFlags struct {
+	Kubeconfig string
+	Overrides  clientcmd.ConfigOverrides
+}
+
+// Bind adds the flags to fs, using the same names and descriptions as kubectl
+func (f *KubeConfigFlags) Bind(fs *flag.FlagSet) {
+	names := clientcmd.RecommendedConfigOverrideFlags("")
+
+	fs.StringVar(&f.Kubeconfig, clientcmd.RecommendedConfigPathFlag, "", "Path to the kubeconfig file to use, defaults to the merged files in KUBECONFIG, then ~/.kube/config")
+	fs.StringVar(&f.Overrides.CurrentContext, names.CurrentContext.LongName, "", names.CurrentContext.Description)
+	fs.StringVar(&f.Overrides.Context.Cluster, names.ContextOverrideFlags.ClusterName.LongName, "", "The name of the kubeconfig cluster to use")
+	fs.StringVar(&f.Overrides.Context.AuthInfo, names.ContextOverrideFlags.AuthInfoName.LongName, "", "The name of the kubeconfig user to use")
+	fs.StringVar(&f.Overrides.ClusterInfo.Server, names.ClusterOverrideFlags.APIServer.LongName, "", names.ClusterOverrideFlags.APIServer.Description)
+	fs.StringVar(&f.Overrides.AuthInfo.Token, names.AuthOverrideFlags.Token.LongName, "", names.AuthOverrideFlags.Token.Description)
+	fs.StringVar(&f.Overrides.AuthInfo.Impersonate, names.AuthOverrideFlags.Impersonate.LongName, "", names.AuthOverrideFlags.Impersonate.Description)
+	fs.Var((*stringSlice)(&f.Overrides.AuthInfo.ImpersonateGroups), names.AuthOverrideFlags.ImpersonateGroups.LongName, names.AuthOverrideFlags.ImpersonateGroups.Description)
+	fs.StringVar(&f.Overrides.Timeout, names.Timeout.LongName, names.Timeout.Default, names.Timeout.Description)
+}
+
+// ClientConfig returns the client config for the flags; without --kubeconfig the files listed in KUBECONFIG
+// are merged, or ~/.kube/config is used, and when none exist but we're running in a pod the pod's service account
+func (f *KubeConfigFlags) ClientConfig() clientcmd.ClientConfig {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = f.Kubeconfig
+
+	overrides := f.Overrides
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &overrides)
+}
+
+// LoadConfig returns the config to talk to the cluster selected by the flags
+func (f *KubeConfigFlags) LoadConfig() (*rest.Config, error) {
+	clientConfig := f.ClientConfig()
+
+	if glog.V(2) {
+		if raw, err := clientConfig.RawConfig(); err == nil {
+			context := raw.CurrentContext
+			if f.Overrides.CurrentContext != "" {
+				context = f.Overrides.CurrentContext
+			}
+			glog.Infof("using kube config context %q", context)
+		}
+	}
+
+	return clientConfig.ClientConfig()
+}
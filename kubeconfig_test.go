@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: %[1]s
+  cluster:
+    server: https://%[1]s.example.com:6443
+contexts:
+- name: %[1]s
+  context:
+    cluster: %[1]s
+    user: %[1]s
+current-context: %[1]s
+users:
+- name: %[1]s
+  user:
+    token: %[1]s-token
+`
+
+// writeKubeconfigs writes one kubeconfig per name to dir, each with a cluster, user and context of that name
+func writeKubeconfigs(t *testing.T, dir string, names ...string) []string {
+	paths := []string{}
+	for _, name := range names {
+		path := filepath.Join(dir, name+".yaml")
+		content := []byte(fmt.Sprintf(testKubeconfig, name))
+		if err := ioutil.WriteFile(path, content, 0600); err != nil {
+			t.Fatalf("failed to write kubeconfig: %v", err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestKubeConfigFlags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kube-smoketest")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	paths := writeKubeconfigs(t, dir, "first", "second")
+	os.Setenv("KUBECONFIG", strings.Join(paths, string(filepath.ListSeparator)))
+	defer os.Unsetenv("KUBECONFIG")
+
+	tests := []struct {
+		name        string
+		args        []string
+		wantHost    string
+		wantToken   string
+		wantAs      string
+		wantGroups  int
+		wantTimeout time.Duration
+	}{
+		{
+			name:      "current context of the first file",
+			wantHost:  "https://first.example.com:6443",
+			wantToken: "first-token",
+		},
+		{
+			name:      "context from a merged file",
+			args:      []string{"--context", "second"},
+			wantHost:  "https://second.example.com:6443",
+			wantToken: "second-token",
+		},
+		{
+			name:      "explicit kubeconfig",
+			args:      []string{"--kubeconfig", paths[1]},
+			wantHost:  "https://second.example.com:6443",
+			wantToken: "second-token",
+		},
+		{
+			name:        "overrides",
+			args:        []string{"--user", "second", "--server", "https://10.0.0.1:6443", "--as", "bob", "--as-group", "a", "--as-group", "b", "--request-timeout", "5s"},
+			wantHost:    "https://10.0.0.1:6443",
+			wantToken:   "second-token",
+			wantAs:      "bob",
+			wantGroups:  2,
+			wantTimeout: 5 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			kubeFlags := KubeConfigFlags{}
+			kubeFlags.Bind(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatalf("failed to parse flags: %v", err)
+			}
+
+			config, err := kubeFlags.LoadConfig()
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+
+			if config.Host != tt.wantHost || config.BearerToken != tt.wantToken {
+				t.Errorf("expected host %s and token %s, got: %s %s", tt.wantHost, tt.wantToken, config.Host, config.BearerToken)
+			}
+			if config.Impersonate.UserName != tt.wantAs || len(config.Impersonate.Groups) != tt.wantGroups {
+				t.Errorf("unexpected impersonation: %+v", config.Impersonate)
+			}
+			if config.Timeout != tt.wantTimeout {
+				t.Errorf("expected timeout %v, got: %v", tt.wantTimeout, config.Timeout)
+			}
+		})
+	}
+}
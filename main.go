@@ -5,155 +5,170 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
-	"time"
 
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/rest"
 
+	"github.com/alex-leonhardt/kube-smoketest/pkg/output"
 	"github.com/alex-leonhardt/kube-smoketest/pkg/smoketests"
 	"github.com/golang/glog"
-	"github.com/hashicorp/go-multierror"
 )
 
 func main() {
-	debug := flag.Bool("debug", false, "do not delete namespace at the end of the test, you must manually delete the NS and wait for it to be gone before re-running kube-smoketest")
+	debug := flag.Bool("debug", false, "do not delete the namespace of the run at the end of the test, delete it with the clean command")
+	outputs := output.Flag{}
+	flag.Var(&outputs, "output", fmt.Sprintf("write the report as format=path, or format to write to stdout, can be repeated; formats: %s", strings.Join(output.Formats(), ", ")))
+	concurrency := flag.Int("concurrency", 4, "max. number of checks to run concurrently, checks only start once the checks they depend on passed")
+	run := flag.String("run", "", "only run the checks with a name matching this regular expression, and the checks they depend on")
+	skip := flag.String("skip", "", "don't run the checks with a name matching this regular expression")
+	tags := flag.String("tags", "", "only run the checks with any of these comma separated tags, and the checks they depend on, e.g. network,security")
+	reuse := flag.Bool("reuse", false, "run alongside the objects earlier runs left behind, e.g. namespaces kept with -debug, instead of failing with a stale state error")
+	recreate := flag.Bool("recreate", false, "delete the objects earlier runs left behind before running, instead of failing with a stale state error")
+	configPath := flag.String("config", "", "path to the YAML config file, see the config print-defaults command; KUBE_SMOKETEST_* environment variables override it")
+	kubeFlags := KubeConfigFlags{}
+	kubeFlags.Bind(flag.CommandLine)
 	flag.Set("logtostderr", "true")
 	flag.Parse()
 
-	kubeconfigpath := os.Getenv("KUBECONFIG")
-
-	if kubeconfigpath == "" {
-		homeDir, _ := os.UserHomeDir()
-		kubeconfigpath = homeDir + "/.kube/config"
+	// the config command works without, or with an invalid, config
+	if flag.Arg(0) == "config" {
+		if err := ConfigCommand(flag.Args()[1:]); err != nil {
+			glog.Fatalln(err.Error())
+		}
+		return
 	}
 
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfigpath)
+	cfg, err := LoadConfig(*configPath)
 	if err != nil {
 		glog.Fatalln(err.Error())
 	}
+	smoketests.Configure(cfg)
 
-	client, err := kubernetes.NewForConfig(config)
+	selection, err := ParseSelection(*run, *skip, *tags)
 	if err != nil {
 		glog.Fatalln(err.Error())
 	}
-
-	// ------------------------
-	errors := multierror.Error{} // collect all errors here...
-	// ------------------------
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-
-	// -------------------------------------------------
-
-	err = smoketests.ComponentStatus(ctx, client)
-	if err != nil {
-		glog.Errorf("\t🔴 Component statuses: %v", err)
-		errors.Errors = append(errors.Errors, err)
-		LogAndExit(errors) // exit early as if components are failed
-	}
-	if err == nil {
-		glog.Infoln("\t✅ Component statuses")
-	}
-
-	// -------------------------------------------------
-
-	err = smoketests.CreateNamespace(ctx, client)
-	if err != nil {
-		glog.Errorf("\t🔴 Create namespace: %v", err)
-		errors.Errors = append(errors.Errors, err)
-		LogAndExit(errors) // exit early as if there's no namespace, then we cannot run
-	}
-	if err == nil {
-		glog.Infoln("\t✅ Create namespace")
-	}
-
-	// -------------------------------------------------
-
-	err = smoketests.PodLogs(ctx, client)
-	if err != nil {
-		errors.Errors = append(errors.Errors, err)
-		glog.Errorf("\t🔴 Pod + Logs: %v", err)
-	}
-	if err == nil {
-		glog.Infoln("\t✅ Pod + Logs")
+	if *reuse && *recreate {
+		glog.Fatalln("-reuse and -recreate are mutually exclusive")
+	}
+
+	opts := SuiteOptions{
+		Concurrency:    *concurrency,
+		Debug:          *debug,
+		Timeout:        cfg.Timeouts.Run.Duration,
+		CleanupTimeout: cfg.Timeouts.Cleanup.Duration,
+		Selection:      selection,
+		Reuse:          *reuse,
+		Recreate:       *recreate,
+	}
+
+	switch cmd := flag.Arg(0); cmd {
+	case "":
+		config, client, dyn := newClient(kubeFlags)
+		ctx, stop := withSignals(context.Background(), false)
+		runCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+		report, err := RunSuite(runCtx, client, dyn, config.Host, opts)
+		cancel()
+		stop()
+		if err != nil {
+			glog.Fatalln(err.Error())
+		}
+		LogAndExit(report, outputs)
+	case "serve":
+		config, client, dyn := newClient(kubeFlags)
+		if err := Serve(client, dyn, config.Host, opts, flag.Args()[1:]); err != nil {
+			glog.Fatalln(err.Error())
+		}
+	case "fanout":
+		failed, err := Fanout(kubeFlags, opts, flag.Args()[1:])
+		if err != nil {
+			glog.Fatalln(err.Error())
+		}
+		glog.Flush()
+		os.Exit(failed) // Exits with the number of clusters that did not pass
+	case "clean":
+		if err := Clean(kubeFlags, opts.CleanupTimeout, flag.Args()[1:]); err != nil {
+			glog.Fatalln(err.Error())
+		}
+	default:
+		glog.Fatalf("unknown command %q, run without a command to run the smoketests once, or use: serve, fanout, clean, config", cmd)
 	}
+}
 
-	// -------------------------------------------------
-
-	err = smoketests.CreateDeployment(ctx, client)
+// ParseSelection returns the selection of checks given by the -run, -skip and -tags flags
+func ParseSelection(run, skip, tags string) (smoketests.Selection, error) {
+	selection := smoketests.Selection{}
+	if run != "" {
+		re, err := regexp.Compile(run)
+		if err != nil {
+			return selection, fmt.Errorf("invalid -run: %v", err)
+		}
+		selection.Run = re
+	}
+	if skip != "" {
+		re, err := regexp.Compile(skip)
+		if err != nil {
+			return selection, fmt.Errorf("invalid -skip: %v", err)
+		}
+		selection.Skip = re
+	}
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			selection.Tags = append(selection.Tags, tag)
+		}
+	}
+
+	// fail early rather than on every run of serve or fanout
+	checks, err := smoketests.EnabledChecks()
 	if err != nil {
-		errors.Errors = append(errors.Errors, err)
-		glog.Errorf("\t🔴 Deployment: %v", err)
+		return selection, err
 	}
-	if err == nil {
-		glog.Infoln("\t✅ Deployment")
-	}
-
-	// -------------------------------------------------
+	_, err = smoketests.Select(checks, selection)
+	return selection, err
+}
 
-	err = smoketests.CreateService(ctx, client)
+// newClient returns the config, client and dynamic client for the cluster selected by flags, it exits if that fails
+func newClient(flags KubeConfigFlags) (*rest.Config, kubernetes.Interface, dynamic.Interface) {
+	config, err := flags.LoadConfig()
 	if err != nil {
-		errors.Errors = append(errors.Errors, err)
-		glog.Errorf("\t🔴 Service: %v", err)
-	}
-	if err == nil {
-		glog.Infoln("\t✅ Service")
+		glog.Fatalln(err.Error())
 	}
 
-	// -------------------------------------------------
-
-	err = smoketests.CreateNodePortService(ctx, client)
+	client, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		errors.Errors = append(errors.Errors, err)
-		glog.Errorf("\t🔴 NodePort Service: %v", err)
-	}
-	if err == nil {
-		glog.Infoln("\t✅ NodePort Service")
+		glog.Fatalln(err.Error())
 	}
 
-	// -------------------------------------------------
-
-	err = smoketests.CreateSecret(ctx, client)
+	dyn, err := dynamic.NewForConfig(config)
 	if err != nil {
-		errors.Errors = append(errors.Errors, err)
-		glog.Errorf("\t🔴 Secret: %v", err)
-	}
-	if err == nil {
-		glog.Infoln("\t✅ Secret")
+		glog.Fatalln(err.Error())
 	}
 
-	// -------------------------------------------------
+	return config, client, dyn
+}
 
-	// don't delete the namespace when debug is set to true
-	if *debug != false {
-		glog.Infoln("\t⚠️  Namespace remains for debugging")
-		LogAndExit(errors)
+// LogAndExit does just that... and writes the report to all outputs
+func LogAndExit(report *smoketests.Report, outputs output.Flag) {
+	if err := outputs.WriteAll(report); err != nil {
+		glog.Errorf("failed to write report: %v", err)
 	}
 
-	err = smoketests.DeleteNamespace(ctx, client)
-	if err != nil {
-		errors.Errors = append(errors.Errors, err)
-		glog.Errorf("\t🔴 Delete namespace: %v", err)
-	}
-	if err == nil {
-		glog.Infoln("\t✅ Delete namespace")
-	}
-
-	LogAndExit(errors)
-}
-
-// LogAndExit does just that...
-func LogAndExit(errors multierror.Error) {
-	fmt.Println("")
-	fmt.Println(strings.Repeat("-", 20), "RESULT", strings.Repeat("-", 20))
-	fmt.Println("")
+	// stdout is reserved for outputs, e.g. -output junit
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, strings.Repeat("-", 20), "RESULT", strings.Repeat("-", 20))
+	fmt.Fprintln(os.Stderr, "")
 
-	if errors.ErrorOrNil() != nil {
-		glog.Errorln("\t🔴 FAILED: too many errors found, expected: 0, actual:", len(errors.Errors))
+	if !report.Passed() {
+		glog.Errorf("\t🔴 FAILED: %d of %d tests did not pass (%d failed, %d errors, %d skipped)",
+			report.ExitCode(), len(report.Results),
+			report.Count(smoketests.StatusFail), report.Count(smoketests.StatusError), report.Count(smoketests.StatusSkip))
+	} else if n := report.Count(smoketests.StatusWarn); n > 0 {
+		glog.Warningf("\t⚠️  SUCCESS: all tests passed, %d with warnings", n)
 	} else {
 		glog.Infoln("\t✅ SUCCESS: all tests passed")
 	}
-	os.Exit(len(errors.Errors)) // Exits > 0 if any errors occured :)
+	os.Exit(report.ExitCode()) // Exits > 0 if any test did not pass :)
 }
@@ -0,0 +1,364 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the configuration of kube-smoketest, see Default for the defaults
+type Config struct {
+	// Namespace configures the namespace of each run, all test resources are created in
+	Namespace Namespace `json:"namespace"`
+	// Labels are added to every object created
+	Labels map[string]string `json:"labels,omitempty"`
+	// Images are the container images used by the tests
+	Images Images `json:"images"`
+	// Timeouts limit how long tests may take
+	Timeouts Timeouts `json:"timeouts"`
+	// Checks configures individual checks by name, e.g. "Secret"
+	Checks map[string]Check `json:"checks,omitempty"`
+
+	Deployment  Deployment  `json:"deployment"`
+	Service     Service     `json:"service"`
+	Secret      Secret      `json:"secret"`
+	ConfigMap   ConfigMap   `json:"configMap"`
+	StatefulSet StatefulSet `json:"statefulSet"`
+	PVC         PVC         `json:"pvc"`
+	Storage     Storage     `json:"storage"`
+	Etcd        Etcd        `json:"etcd"`
+}
+
+// Namespace configures the namespace of each run
+type Namespace struct {
+	// Prefix is followed by the run id to name the namespace of a run, e.g. kube-smoketest-0123456789ab
+	Prefix string `json:"prefix"`
+	// Labels are added to the namespace only, in addition to Config.Labels
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Images are the container images used by the tests
+type Images struct {
+	// Pod is the image of the pod whose logs are retrieved
+	Pod string `json:"pod"`
+	// Job is the image of the jobs testing access to services, it must provide /bin/sh and wget
+	Job string `json:"job"`
+	// Deployment is the image of the deployment the services point at, it must serve http on port 80
+	Deployment string `json:"deployment"`
+	// StatefulSet is the image of the statefulset's pods, it must serve http on port 80
+	StatefulSet string `json:"statefulSet"`
+}
+
+// Timeouts limit how long tests may take
+type Timeouts struct {
+	// Run is the max. duration of a whole run, all checks share this budget
+	Run metav1.Duration `json:"run"`
+	// Check is the max. duration of a check without a default timeout of its own
+	Check metav1.Duration `json:"check"`
+	// Cleanup is the max. duration of removing what a run created, it starts when the run ended, so
+	// cleanup happens even if the run timed out or was interrupted
+	Cleanup metav1.Duration `json:"cleanup"`
+	// Unschedulable is how long a pod may be unschedulable before its check fails, e.g. for the cluster
+	// autoscaler to add a node; 0, the default, fails the check right away
+	Unschedulable metav1.Duration `json:"unschedulable,omitempty"`
+}
+
+// Check configures a single check
+type Check struct {
+	// Enabled can disable a check, checks are enabled by default
+	Enabled *bool `json:"enabled,omitempty"`
+	// Timeout overrides the check's default timeout
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+}
+
+// Deployment configures the Deployment test
+type Deployment struct {
+	Name            string `json:"name"`
+	Replicas        int32  `json:"replicas"`
+	MinReadySeconds int32  `json:"minReadySeconds"`
+}
+
+// Service configures the Service and NodePort Service tests
+type Service struct {
+	Name         string `json:"name"`
+	NodePortName string `json:"nodePortName"`
+}
+
+// Secret configures the Secret and Secret + Pod tests
+type Secret struct {
+	Name string `json:"name"`
+	// PodName is the name of the secret the Secret + Pod test creates and reads from a pod
+	PodName string `json:"podName"`
+}
+
+// ConfigMap configures the ConfigMap test
+type ConfigMap struct {
+	Name string `json:"name"`
+	// PropagationBudget is how long the kubelet may take to update a mounted volume once the ConfigMap changed,
+	// the kubelet syncs pods every minute by default and may serve the ConfigMap from its cache; the check's
+	// timeout must leave time for it
+	PropagationBudget metav1.Duration `json:"propagationBudget"`
+}
+
+// StatefulSet configures the StatefulSet test, its headless service has the same name
+type StatefulSet struct {
+	Name string `json:"name"`
+	// Replicas must be at least 2, the test deletes and expects back the pod with ordinal 1
+	Replicas int32 `json:"replicas"`
+}
+
+// PVC configures the PersistentVolumeClaim test
+type PVC struct {
+	Name string `json:"name"`
+	// OtherNode reads the volume back on another node than the one it was written on, the volume must be
+	// attachable there, e.g. in the same zone
+	OtherNode bool `json:"otherNode,omitempty"`
+}
+
+// Storage configures the volumes the tests create
+type Storage struct {
+	// ClassName is the StorageClass of the volumes, the cluster's default StorageClass when empty
+	ClassName string `json:"className,omitempty"`
+	// Size is the requested size of each volume, e.g. 1Gi
+	Size string `json:"size"`
+}
+
+// Etcd configures how the Secret test connects to etcd
+type Etcd struct {
+	// Endpoints are the etcd endpoints as host:port, when empty the InternalIPs of the nodes matching
+	// NodeSelector are used with Port
+	Endpoints    []string        `json:"endpoints,omitempty"`
+	NodeSelector string          `json:"nodeSelector"`
+	Port         int             `json:"port"`
+	CA           string          `json:"ca"`
+	Cert         string          `json:"cert"`
+	Key          string          `json:"key"`
+	DialTimeout  metav1.Duration `json:"dialTimeout"`
+}
+
+// Default returns the default configuration
+func Default() *Config {
+	return &Config{
+		Namespace: Namespace{
+			Prefix: "kube-smoketest",
+		},
+		Images: Images{
+			Pod:         "alpine",
+			Job:         "busybox",
+			Deployment:  "nginx",
+			StatefulSet: "nginx",
+		},
+		Timeouts: Timeouts{
+			Run:     metav1.Duration{Duration: 10 * time.Minute},
+			Check:   metav1.Duration{Duration: 2 * time.Minute},
+			Cleanup: metav1.Duration{Duration: 2 * time.Minute},
+		},
+		Deployment: Deployment{
+			Name:            "smoketest",
+			Replicas:        2,
+			MinReadySeconds: 7,
+		},
+		Service: Service{
+			Name:         "smoketest-service",
+			NodePortName: "smoketest-service-np",
+		},
+		Secret: Secret{
+			Name:    "smoketest-secret",
+			PodName: "smoketest-secret-pod",
+		},
+		ConfigMap: ConfigMap{
+			Name:              "smoketest-config",
+			PropagationBudget: metav1.Duration{Duration: 2 * time.Minute},
+		},
+		StatefulSet: StatefulSet{
+			Name:     "web",
+			Replicas: 3,
+		},
+		PVC: PVC{
+			Name: "smoketest-pvc",
+		},
+		Storage: Storage{
+			Size: "1Gi",
+		},
+		Etcd: Etcd{
+			NodeSelector: "node-role.kubernetes.io/master=",
+			Port:         2379,
+			CA:           "./etcd.ca",
+			Cert:         "./etcd.crt",
+			Key:          "./etcd.key",
+			DialTimeout:  metav1.Duration{Duration: time.Second},
+		},
+	}
+}
+
+// Load returns the default configuration, overridden by the YAML file at path if path is not empty,
+// and by environment variables, see Env; the result is validated against checkNames, the names of all known checks
+func Load(path string, checkNames []string) (*Config, error) {
+	c := Default()
+
+	if path != "" {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config: %v", err)
+		}
+		if err := yaml.UnmarshalStrict(b, c); err != nil {
+			return nil, fmt.Errorf("failed to parse config %s: %v", path, err)
+		}
+	}
+
+	if err := c.ApplyEnv(Getenv); err != nil {
+		return nil, err
+	}
+
+	if err := c.Validate(checkNames); err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+
+	return c, nil
+}
+
+// CheckEnabled returns true unless the check called name was disabled
+func (c *Config) CheckEnabled(name string) bool {
+	check, ok := c.Checks[name]
+	if !ok || check.Enabled == nil {
+		return true
+	}
+	return *check.Enabled
+}
+
+// CheckTimeout returns the timeout configured for the check called name, or 0 if there is none
+func (c *Config) CheckTimeout(name string) time.Duration {
+	return c.Checks[name].Timeout.Duration
+}
+
+// Validate returns an error listing everything wrong with the config, checkNames are the names of all known checks
+func (c *Config) Validate(checkNames []string) error {
+	multierr := multierror.Error{}
+	invalid := func(format string, args ...interface{}) {
+		multierr.Errors = append(multierr.Errors, fmt.Errorf(format, args...))
+	}
+
+	// the run id is 12 characters
+	for _, msg := range validation.IsDNS1123Label(c.Namespace.Prefix + "-0123456789ab") {
+		invalid("namespace.prefix %q: %s", c.Namespace.Prefix, msg)
+	}
+	validateLabels("labels", c.Labels, invalid)
+	validateLabels("namespace.labels", c.Namespace.Labels, invalid)
+
+	for field, image := range map[string]string{"images.pod": c.Images.Pod, "images.job": c.Images.Job, "images.deployment": c.Images.Deployment, "images.statefulSet": c.Images.StatefulSet} {
+		if strings.TrimSpace(image) == "" {
+			invalid("%s must not be empty", field)
+		}
+	}
+
+	if c.Timeouts.Run.Duration <= 0 {
+		invalid("timeouts.run must be greater than 0, got: %v", c.Timeouts.Run.Duration)
+	}
+	if c.Timeouts.Check.Duration <= 0 {
+		invalid("timeouts.check must be greater than 0, got: %v", c.Timeouts.Check.Duration)
+	}
+	if c.Timeouts.Cleanup.Duration <= 0 {
+		invalid("timeouts.cleanup must be greater than 0, got: %v", c.Timeouts.Cleanup.Duration)
+	}
+	if c.Timeouts.Unschedulable.Duration < 0 {
+		invalid("timeouts.unschedulable must not be negative, got: %v", c.Timeouts.Unschedulable.Duration)
+	}
+
+	known := map[string]bool{}
+	for _, name := range checkNames {
+		known[name] = true
+	}
+	for name, check := range c.Checks {
+		if !known[name] {
+			invalid("checks: unknown check %q, must be one of: %s", name, strings.Join(checkNames, ", "))
+		}
+		if check.Timeout.Duration < 0 {
+			invalid("checks: %q: timeout must not be negative, got: %v", name, check.Timeout.Duration)
+		}
+	}
+
+	for field, name := range map[string]string{
+		"deployment.name":      c.Deployment.Name,
+		"service.name":         c.Service.Name,
+		"service.nodePortName": c.Service.NodePortName,
+		"secret.name":          c.Secret.Name,
+		"secret.podName":       c.Secret.PodName,
+		"configMap.name":       c.ConfigMap.Name,
+		"statefulSet.name":     c.StatefulSet.Name,
+		"pvc.name":             c.PVC.Name,
+	} {
+		for _, msg := range validation.IsDNS1035Label(name) {
+			invalid("%s %q: %s", field, name, msg)
+		}
+	}
+	if c.Service.Name == c.Service.NodePortName {
+		invalid("service.name and service.nodePortName must differ, both are %q", c.Service.Name)
+	}
+	if c.Secret.Name == c.Secret.PodName {
+		invalid("secret.name and secret.podName must differ, both are %q", c.Secret.Name)
+	}
+	if c.Deployment.Replicas < 1 {
+		invalid("deployment.replicas must be at least 1, got: %d", c.Deployment.Replicas)
+	}
+	if c.Deployment.MinReadySeconds < 0 {
+		invalid("deployment.minReadySeconds must not be negative, got: %d", c.Deployment.MinReadySeconds)
+	}
+
+	if c.ConfigMap.PropagationBudget.Duration <= 0 {
+		invalid("configMap.propagationBudget must be greater than 0, got: %v", c.ConfigMap.PropagationBudget.Duration)
+	}
+
+	if c.StatefulSet.Replicas < 2 {
+		invalid("statefulSet.replicas must be at least 2, got: %d", c.StatefulSet.Replicas)
+	}
+	if c.StatefulSet.Name == c.Service.Name || c.StatefulSet.Name == c.Service.NodePortName {
+		invalid("statefulSet.name must differ from service.name and service.nodePortName, it names the statefulset's service too, got: %q", c.StatefulSet.Name)
+	}
+
+	if c.Storage.ClassName != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(c.Storage.ClassName) {
+			invalid("storage.className %q: %s", c.Storage.ClassName, msg)
+		}
+	}
+	if size, err := resource.ParseQuantity(c.Storage.Size); err != nil {
+		invalid("storage.size %q: %v", c.Storage.Size, err)
+	} else if size.Sign() <= 0 {
+		invalid("storage.size must be greater than 0, got: %q", c.Storage.Size)
+	}
+
+	if c.Etcd.Port < 1 || c.Etcd.Port > 65535 {
+		invalid("etcd.port must be between 1 and 65535, got: %d", c.Etcd.Port)
+	}
+	if len(c.Etcd.Endpoints) == 0 && c.Etcd.NodeSelector == "" {
+		invalid("etcd.nodeSelector must not be empty without etcd.endpoints")
+	}
+	if c.Etcd.DialTimeout.Duration <= 0 {
+		invalid("etcd.dialTimeout must be greater than 0, got: %v", c.Etcd.DialTimeout.Duration)
+	}
+
+	return multierr.ErrorOrNil()
+}
+
+// validateLabels validates the keys and values of labels in field
+func validateLabels(field string, labels map[string]string, invalid func(format string, args ...interface{})) {
+	for k, v := range labels {
+		for _, msg := range validation.IsQualifiedName(k) {
+			invalid("%s: key %q: %s", field, k, msg)
+		}
+		for _, msg := range validation.IsValidLabelValue(v) {
+			invalid("%s: value %q of %q: %s", field, v, k, msg)
+		}
+	}
+}
+
+// YAML returns the config as YAML
+func (c *Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testChecks = []string{"Create namespace", "Deployment", "Secret"}
+
+// writeConfig writes content to a config file in a new temp dir, and returns its path
+func writeConfig(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "kube-smoketest")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return path
+}
+
+// setenv makes Getenv return env until the test finished
+func setenv(t *testing.T, env map[string]string) {
+	orig := Getenv
+	Getenv = func(name string) string { return env[name] }
+	t.Cleanup(func() { Getenv = orig })
+}
+
+func TestDefaultIsValid(t *testing.T) {
+	if err := Default().Validate(testChecks); err != nil {
+		t.Fatalf("expected the defaults to be valid, got: %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	setenv(t, nil)
+	path := writeConfig(t, `
+namespace:
+  prefix: smoke
+labels:
+  team: platform
+images:
+  deployment: registry.example.com/nginx:1.19
+timeouts:
+  run: 10m
+checks:
+  Secret:
+    enabled: false
+  Deployment:
+    timeout: 4m
+deployment:
+  replicas: 3
+`)
+
+	c, err := Load(path, testChecks)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if c.Namespace.Prefix != "smoke" {
+		t.Errorf("expected namespace prefix smoke, got: %q", c.Namespace.Prefix)
+	}
+	if c.Labels["team"] != "platform" {
+		t.Errorf("expected label team=platform, got: %v", c.Labels)
+	}
+	if c.Images.Deployment != "registry.example.com/nginx:1.19" || c.Images.Pod != "alpine" {
+		t.Errorf("expected the deployment image to be overridden and the pod image default, got: %+v", c.Images)
+	}
+	if c.Timeouts.Run.Duration != 10*time.Minute {
+		t.Errorf("expected run timeout 10m, got: %v", c.Timeouts.Run.Duration)
+	}
+	if c.Deployment.Replicas != 3 || c.Deployment.Name != "smoketest" {
+		t.Errorf("expected 3 replicas of the default deployment, got: %+v", c.Deployment)
+	}
+	if c.CheckEnabled("Secret") || !c.CheckEnabled("Deployment") {
+		t.Errorf("expected only Secret to be disabled, got: %+v", c.Checks)
+	}
+	if c.CheckTimeout("Deployment") != 4*time.Minute || c.CheckTimeout("Secret") != 0 {
+		t.Errorf("expected a timeout for Deployment only, got: %+v", c.Checks)
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	setenv(t, map[string]string{
+		"KUBE_SMOKETEST_NAMESPACE_PREFIX": "from-env",
+		"KUBE_SMOKETEST_LABELS":           "a=1, b=2",
+		"KUBE_SMOKETEST_TIMEOUT_RUN":      "90s",
+		"KUBE_SMOKETEST_DISABLED_CHECKS":  "Secret,Deployment",
+		"KUBE_SMOKETEST_ETCD_ENDPOINTS":   "10.0.0.1:2379, 10.0.0.2:2379",
+	})
+	path := writeConfig(t, "namespace:\n  prefix: from-file\n")
+
+	c, err := Load(path, testChecks)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if c.Namespace.Prefix != "from-env" {
+		t.Errorf("expected the environment to override the file, got namespace prefix: %q", c.Namespace.Prefix)
+	}
+	if len(c.Labels) != 2 || c.Labels["b"] != "2" {
+		t.Errorf("expected labels a=1,b=2, got: %v", c.Labels)
+	}
+	if c.Timeouts.Run.Duration != 90*time.Second {
+		t.Errorf("expected run timeout 90s, got: %v", c.Timeouts.Run.Duration)
+	}
+	if c.CheckEnabled("Secret") || c.CheckEnabled("Deployment") {
+		t.Errorf("expected Secret and Deployment to be disabled, got: %+v", c.Checks)
+	}
+	if len(c.Etcd.Endpoints) != 2 || c.Etcd.Endpoints[1] != "10.0.0.2:2379" {
+		t.Errorf("unexpected etcd endpoints: %v", c.Etcd.Endpoints)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		env     map[string]string
+		wantErr string
+	}{
+		{"unknown field", "namespace:\n  nmae: typo\n", nil, `unknown field "nmae"`},
+		{"invalid namespace", "namespace:\n  prefix: Not_Valid\n", nil, "namespace.prefix"},
+		{"namespace too long", "namespace:\n  prefix: " + strings.Repeat("a", 51) + "\n", nil, "namespace.prefix"},
+		{"unknown check", "checks:\n  Secrets:\n    enabled: false\n", nil, `unknown check "Secrets"`},
+		{"negative check timeout", "checks:\n  Secret:\n    timeout: -1s\n", nil, "timeout must not be negative"},
+		{"no cleanup timeout", "timeouts:\n  cleanup: 0s\n", nil, "timeouts.cleanup"},
+		{"negative unschedulable timeout", "timeouts:\n  unschedulable: -1m\n", nil, "timeouts.unschedulable"},
+		{"no replicas", "deployment:\n  replicas: 0\n", nil, "deployment.replicas"},
+		{"no propagation budget", "configMap:\n  propagationBudget: 0s\n", nil, "configMap.propagationBudget"},
+		{"one statefulset replica", "statefulSet:\n  replicas: 1\n", nil, "statefulSet.replicas"},
+		{"invalid volume size", "storage:\n  size: lots\n", nil, "storage.size"},
+		{"invalid label", "labels:\n  team: not valid\n", nil, "labels"},
+		{"empty image", "images:\n  job: \"\"\n", nil, "images.job"},
+		{"invalid port", "etcd:\n  port: 70000\n", nil, "etcd.port"},
+		{"invalid env", "", map[string]string{"KUBE_SMOKETEST_TIMEOUT_RUN": "soon"}, "KUBE_SMOKETEST_TIMEOUT_RUN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setenv(t, tt.env)
+			_, err := Load(writeConfig(t, tt.config), testChecks)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error to contain %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	setenv(t, nil)
+
+	b, err := Default().YAML()
+	if err != nil {
+		t.Fatalf("failed to marshal defaults: %v", err)
+	}
+
+	c, err := Load(writeConfig(t, string(b)), testChecks)
+	if err != nil {
+		t.Fatalf("failed to load the marshalled defaults: %v", err)
+	}
+	if c.Timeouts.Run != Default().Timeouts.Run || c.Etcd.Port != Default().Etcd.Port {
+		t.Errorf("expected the defaults, got: %+v", c)
+	}
+}
@@ -0,0 +1,2 @@
+// Package config loads the kube-smoketest configuration from a YAML file and environment variables.
+package config
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EnvPrefix prefixes all environment variables overriding the config
+const EnvPrefix = "KUBE_SMOKETEST_"
+
+// Getenv looks up environment variables, it's os.Getenv
+var Getenv = os.Getenv
+
+// envVar overrides a config setting with the value of an environment variable
+type envVar struct {
+	name  string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+// envVars are all environment variables overriding the config, in the order they're applied
+var envVars = []envVar{
+	{"NAMESPACE_PREFIX", "namespace.prefix", func(c *Config, v string) error { c.Namespace.Prefix = v; return nil }},
+	{"LABELS", "labels, as k=v,k2=v2", func(c *Config, v string) error { return setLabels(&c.Labels, v) }},
+	{"IMAGE_POD", "images.pod", func(c *Config, v string) error { c.Images.Pod = v; return nil }},
+	{"IMAGE_JOB", "images.job", func(c *Config, v string) error { c.Images.Job = v; return nil }},
+	{"IMAGE_DEPLOYMENT", "images.deployment", func(c *Config, v string) error { c.Images.Deployment = v; return nil }},
+	{"IMAGE_STATEFULSET", "images.statefulSet", func(c *Config, v string) error { c.Images.StatefulSet = v; return nil }},
+	{"STORAGE_CLASS", "storage.className", func(c *Config, v string) error { c.Storage.ClassName = v; return nil }},
+	{"CONFIGMAP_PROPAGATION_BUDGET", "configMap.propagationBudget, e.g. 2m", func(c *Config, v string) error { return setDuration(&c.ConfigMap.PropagationBudget, v) }},
+	{"PVC_OTHER_NODE", "pvc.otherNode, true or false", func(c *Config, v string) (err error) { c.PVC.OtherNode, err = strconv.ParseBool(v); return err }},
+	{"TIMEOUT_RUN", "timeouts.run, e.g. 5m", func(c *Config, v string) error { return setDuration(&c.Timeouts.Run, v) }},
+	{"TIMEOUT_CHECK", "timeouts.check, e.g. 2m", func(c *Config, v string) error { return setDuration(&c.Timeouts.Check, v) }},
+	{"TIMEOUT_CLEANUP", "timeouts.cleanup, e.g. 2m", func(c *Config, v string) error { return setDuration(&c.Timeouts.Cleanup, v) }},
+	{"TIMEOUT_UNSCHEDULABLE", "timeouts.unschedulable, e.g. 5m", func(c *Config, v string) error { return setDuration(&c.Timeouts.Unschedulable, v) }},
+	{"DISABLED_CHECKS", "comma separated names of checks to disable", func(c *Config, v string) error { disableChecks(c, v); return nil }},
+	{"ETCD_ENDPOINTS", "etcd.endpoints, comma separated", func(c *Config, v string) error { c.Etcd.Endpoints = splitList(v); return nil }},
+	{"ETCD_PORT", "etcd.port", func(c *Config, v string) (err error) { c.Etcd.Port, err = strconv.Atoi(v); return err }},
+	{"ETCD_CA", "etcd.ca", func(c *Config, v string) error { c.Etcd.CA = v; return nil }},
+	{"ETCD_CERT", "etcd.cert", func(c *Config, v string) error { c.Etcd.Cert = v; return nil }},
+	{"ETCD_KEY", "etcd.key", func(c *Config, v string) error { c.Etcd.Key = v; return nil }},
+}
+
+// Env returns the names of the environment variables overriding the config, and what they override
+func Env() [][2]string {
+	env := [][2]string{}
+	for _, e := range envVars {
+		env = append(env, [2]string{EnvPrefix + e.name, e.usage})
+	}
+	return env
+}
+
+// ApplyEnv overrides the config with the environment variables that are set and not empty, as returned by getenv
+func (c *Config) ApplyEnv(getenv func(string) string) error {
+	for _, e := range envVars {
+		name := EnvPrefix + e.name
+		value := strings.TrimSpace(getenv(name))
+		if value == "" {
+			continue
+		}
+		if err := e.set(c, value); err != nil {
+			return fmt.Errorf("invalid %s=%q: %v", name, value, err)
+		}
+	}
+	return nil
+}
+
+// splitList splits a comma separated list, dropping empty items
+func splitList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func setLabels(labels *map[string]string, value string) error {
+	if *labels == nil {
+		*labels = map[string]string{}
+	}
+	for _, kv := range splitList(value) {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("expected key=value, got: %q", kv)
+		}
+		(*labels)[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return nil
+}
+
+func setDuration(d *metav1.Duration, value string) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+func disableChecks(c *Config, value string) {
+	if c.Checks == nil {
+		c.Checks = map[string]Check{}
+	}
+	disabled := false
+	for _, name := range splitList(value) {
+		check := c.Checks[name]
+		check.Enabled = &disabled
+		c.Checks[name] = check
+	}
+}
@@ -0,0 +1,2 @@
+// Package metrics exposes the results of kube-smoketest runs as prometheus metrics.
+package metrics
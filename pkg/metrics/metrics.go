@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"github.com/alex-leonhardt/kube-smoketest/pkg/smoketests"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "kube_smoketest"
+
+// Metrics holds the prometheus metrics describing the runs of the smoketest suite
+type Metrics struct {
+	checkSuccess       *prometheus.GaugeVec
+	checkStatus        *prometheus.GaugeVec
+	checkDuration      *prometheus.HistogramVec
+	checkLastRun       *prometheus.GaugeVec
+	waitDuration       *prometheus.HistogramVec
+	runsTotal          *prometheus.CounterVec
+	runDuration        prometheus.Histogram
+	lastRun            prometheus.Gauge
+	lastRunSuccess     prometheus.Gauge
+	runsMissedInterval prometheus.Counter
+}
+
+// statuses are all statuses a check can have, used to reset kube_smoketest_check_status
+var statuses = []smoketests.Status{
+	smoketests.StatusPass,
+	smoketests.StatusWarn,
+	smoketests.StatusFail,
+	smoketests.StatusError,
+	smoketests.StatusSkip,
+}
+
+// New creates the metrics and registers them with reg
+func New(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		checkSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "check_success",
+			Help:      "Whether the check passed in the last run, 1 if it passed (possibly with warnings), 0 otherwise.",
+		}, []string{"check"}),
+		checkStatus: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "check_status",
+			Help:      "The status of the check in the last run, 1 for the check's current status, 0 for all others.",
+		}, []string{"check", "status"}),
+		checkDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "check_duration_seconds",
+			Help:      "How long the check took to run, checks that were skipped are not observed.",
+			Buckets:   []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300},
+		}, []string{"check"}),
+		checkLastRun: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "check_last_run_timestamp_seconds",
+			Help:      "Unix timestamp the check last finished.",
+		}, []string{"check"}),
+		waitDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "wait_duration_seconds",
+			Help:      "How long it took a resource the check created to reach the state the check waited for, e.g. a pod to run.",
+			Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+		}, []string{"check", "resource"}),
+		runsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "runs_total",
+			Help:      "Number of runs of the smoketest suite, by verdict.",
+		}, []string{"verdict"}),
+		runDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "run_duration_seconds",
+			Help:      "How long a run of the smoketest suite took.",
+			Buckets:   []float64{10, 20, 30, 60, 90, 120, 180, 300, 600},
+		}),
+		lastRun: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "last_run_timestamp_seconds",
+			Help:      "Unix timestamp the last run of the smoketest suite finished.",
+		}),
+		lastRunSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "last_run_success",
+			Help:      "Whether all checks passed in the last run, 1 if they did, 0 otherwise.",
+		}),
+		runsMissedInterval: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "runs_missed_interval_total",
+			Help:      "Number of runs that started late, as the previous run took longer than the interval.",
+		}),
+	}
+
+	reg.MustRegister(
+		m.checkSuccess,
+		m.checkStatus,
+		m.checkDuration,
+		m.checkLastRun,
+		m.waitDuration,
+		m.runsTotal,
+		m.runDuration,
+		m.lastRun,
+		m.lastRunSuccess,
+		m.runsMissedInterval,
+	)
+
+	return m
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Observe updates the metrics from a run's report
+func (m *Metrics) Observe(report *smoketests.Report) {
+	for _, result := range report.Results {
+		m.checkSuccess.WithLabelValues(result.Name).Set(boolToFloat(result.Status.Passed()))
+		for _, status := range statuses {
+			m.checkStatus.WithLabelValues(result.Name, string(status)).Set(boolToFloat(result.Status == status))
+		}
+		if result.Status == smoketests.StatusSkip {
+			continue
+		}
+		m.checkDuration.WithLabelValues(result.Name).Observe(result.Duration.Seconds())
+		m.checkLastRun.WithLabelValues(result.Name).Set(float64(result.End.Unix()))
+		for _, w := range result.Waits {
+			m.waitDuration.WithLabelValues(result.Name, w.Resource).Observe(w.Duration().Seconds())
+		}
+	}
+
+	m.runsTotal.WithLabelValues(string(report.Verdict())).Inc()
+	m.runDuration.Observe(report.End.Sub(report.Start).Seconds())
+	m.lastRun.Set(float64(report.End.Unix()))
+	m.lastRunSuccess.Set(boolToFloat(report.Passed()))
+}
+
+// MissedInterval counts a run that started late as the previous run was still in progress
+func (m *Metrics) MissedInterval() {
+	m.runsMissedInterval.Inc()
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alex-leonhardt/kube-smoketest/pkg/smoketests"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserve(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := New(reg)
+
+	end := time.Date(2020, 5, 2, 19, 2, 39, 0, time.UTC)
+	report := &smoketests.Report{Start: end.Add(-time.Minute)}
+	report.Add(
+		&smoketests.Result{Name: "Service", Status: smoketests.StatusPass, Duration: 2 * time.Second, End: end},
+		&smoketests.Result{Name: "Secret", Status: smoketests.StatusWarn, Duration: time.Second, End: end},
+		&smoketests.Result{Name: "Deployment", Status: smoketests.StatusFail, Duration: 30 * time.Second, End: end, Waits: []smoketests.Wait{
+			{Resource: "namespaces", Start: end.Add(-30 * time.Second), Reached: end.Add(-29 * time.Second)},
+			{Resource: "deployments.apps", Start: end.Add(-29 * time.Second), Reached: end.Add(-15 * time.Second)},
+		}},
+		&smoketests.Result{Name: "NodePort Service", Status: smoketests.StatusSkip},
+	)
+	report.End = end
+
+	m.Observe(report)
+
+	expected := `
+# HELP kube_smoketest_check_success Whether the check passed in the last run, 1 if it passed (possibly with warnings), 0 otherwise.
+# TYPE kube_smoketest_check_success gauge
+kube_smoketest_check_success{check="Deployment"} 0
+kube_smoketest_check_success{check="NodePort Service"} 0
+kube_smoketest_check_success{check="Secret"} 1
+kube_smoketest_check_success{check="Service"} 1
+# HELP kube_smoketest_runs_total Number of runs of the smoketest suite, by verdict.
+# TYPE kube_smoketest_runs_total counter
+kube_smoketest_runs_total{verdict="fail"} 1
+# HELP kube_smoketest_last_run_success Whether all checks passed in the last run, 1 if they did, 0 otherwise.
+# TYPE kube_smoketest_last_run_success gauge
+kube_smoketest_last_run_success 0
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"kube_smoketest_check_success", "kube_smoketest_runs_total", "kube_smoketest_last_run_success"); err != nil {
+		t.Error(err)
+	}
+
+	if n := testutil.CollectAndCount(m.checkDuration); n != 3 {
+		t.Errorf("expected durations of 3 checks, skipped checks excluded, got: %d", n)
+	}
+	if n := testutil.CollectAndCount(m.waitDuration); n != 2 {
+		t.Errorf("expected the durations of 2 waits, got: %d", n)
+	}
+	if v := testutil.ToFloat64(m.checkLastRun.WithLabelValues("Service")); v != float64(end.Unix()) {
+		t.Errorf("expected last run timestamp %d, got: %v", end.Unix(), v)
+	}
+	if v := testutil.ToFloat64(m.checkStatus.WithLabelValues("Secret", "warn")); v != 1 {
+		t.Errorf("expected Secret to have status warn, got: %v", v)
+	}
+}
@@ -0,0 +1,2 @@
+// Package output writes the report of a kube-smoketest run in machine readable formats.
+package output
@@ -0,0 +1,104 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/alex-leonhardt/kube-smoketest/pkg/smoketests"
+)
+
+// JSONSchemaVersion is the version of the JSON report's schema, see docs/report-schema.md;
+// it changes whenever a field is removed or changes its meaning, new fields may be added at any time
+const JSONSchemaVersion = "v1"
+
+type jsonReport struct {
+	SchemaVersion   string         `json:"schemaVersion"`
+	RunID           string         `json:"runId"`
+	Namespace       string         `json:"namespace"`
+	Cluster         jsonCluster    `json:"cluster"`
+	Verdict         string         `json:"verdict"`
+	Start           time.Time      `json:"start"`
+	End             time.Time      `json:"end"`
+	DurationSeconds float64        `json:"durationSeconds"`
+	Summary         map[string]int `json:"summary"`
+	Checks          []jsonCheck    `json:"checks"`
+}
+
+type jsonCluster struct {
+	Server  string `json:"server"`
+	Version string `json:"version"`
+}
+
+type jsonCheck struct {
+	Name            string     `json:"name"`
+	Description     string     `json:"description"`
+	Tags            []string   `json:"tags"`
+	Status          string     `json:"status"`
+	Start           *time.Time `json:"start,omitempty"`
+	End             *time.Time `json:"end,omitempty"`
+	DurationSeconds float64    `json:"durationSeconds"`
+	Error           string     `json:"error,omitempty"`
+	Warnings        []string   `json:"warnings"`
+	Diagnostics     []string   `json:"diagnostics"`
+}
+
+// optionalTime returns nil for the zero time, so it's omitted from the output
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// nonNil returns s, or an empty slice so it's encoded as [] rather than null
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// WriteJSON writes report as JSON, see docs/report-schema.md for the schema
+func WriteJSON(w io.Writer, report *smoketests.Report) error {
+	out := jsonReport{
+		SchemaVersion: JSONSchemaVersion,
+		RunID:         report.RunID,
+		Namespace:     report.Namespace,
+		Cluster: jsonCluster{
+			Server:  report.Cluster.Server,
+			Version: report.Cluster.Version,
+		},
+		Verdict:         string(report.Verdict()),
+		Start:           report.Start,
+		End:             report.End,
+		DurationSeconds: report.End.Sub(report.Start).Seconds(),
+		Summary: map[string]int{
+			"total": len(report.Results),
+		},
+		Checks: []jsonCheck{},
+	}
+
+	for _, status := range []smoketests.Status{smoketests.StatusPass, smoketests.StatusWarn, smoketests.StatusFail, smoketests.StatusError, smoketests.StatusSkip} {
+		out.Summary[string(status)] = report.Count(status)
+	}
+
+	for _, result := range report.Results {
+		out.Checks = append(out.Checks, jsonCheck{
+			Name:            result.Name,
+			Description:     result.Description,
+			Tags:            nonNil(result.Tags),
+			Status:          string(result.Status),
+			Start:           optionalTime(result.Start),
+			End:             optionalTime(result.End),
+			DurationSeconds: result.Duration.Seconds(),
+			Error:           result.Message,
+			Warnings:        nonNil(result.Warnings),
+			Diagnostics:     nonNil(result.Diagnostics),
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,50 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/alex-leonhardt/kube-smoketest/pkg/smoketests"
+)
+
+func TestWriteJSON(t *testing.T) {
+	report := testReport()
+	report.RunID = "0123456789ab"
+	report.Namespace = "kube-smoketest-0123456789ab"
+	report.Cluster = smoketests.Cluster{Server: "https://10.0.0.1:6443", Version: "v1.18.2"}
+
+	buf := &bytes.Buffer{}
+	if err := WriteJSON(buf, report); err != nil {
+		t.Fatalf("failed to write json: %v", err)
+	}
+
+	out := jsonReport{}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("failed to parse json output: %v\n%s", err, buf.String())
+	}
+
+	if out.SchemaVersion != JSONSchemaVersion || out.RunID != "0123456789ab" || out.Namespace != "kube-smoketest-0123456789ab" {
+		t.Errorf("unexpected schema version, run id or namespace: %+v", out)
+	}
+	if out.Cluster.Server != "https://10.0.0.1:6443" || out.Cluster.Version != "v1.18.2" {
+		t.Errorf("unexpected cluster: %+v", out.Cluster)
+	}
+	if out.Verdict != "fail" || out.Summary["total"] != 5 || out.Summary["skip"] != 1 {
+		t.Errorf("unexpected verdict or summary: %s %v", out.Verdict, out.Summary)
+	}
+	if out.DurationSeconds != 51 {
+		t.Errorf("expected a duration of 51s, got: %v", out.DurationSeconds)
+	}
+
+	deployment := out.Checks[1]
+	if deployment.Status != "fail" || deployment.Error != "context deadline exceeded" || deployment.DurationSeconds != 30 {
+		t.Errorf("unexpected deployment check: %+v", deployment)
+	}
+	if secret := out.Checks[3]; secret.Status != "warn" || len(secret.Warnings) != 1 {
+		t.Errorf("unexpected secret check: %+v", secret)
+	}
+	if out.Checks[0].Warnings == nil || out.Checks[0].Tags == nil {
+		t.Errorf("expected empty lists rather than null: %+v", out.Checks[0])
+	}
+}
@@ -0,0 +1,117 @@
+package output
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/alex-leonhardt/kube-smoketest/pkg/smoketests"
+)
+
+// junitTestSuites is the root element of a JUnit XML report
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitSuiteName is the name of the test suite and the class name of all test cases
+const junitSuiteName = "kube-smoketest"
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// WriteJUnit writes report as JUnit XML, with one testcase per check
+func WriteJUnit(w io.Writer, report *smoketests.Report) error {
+	suite := junitTestSuite{
+		Name:      junitSuiteName,
+		Tests:     len(report.Results),
+		Failures:  report.Count(smoketests.StatusFail),
+		Errors:    report.Count(smoketests.StatusError),
+		Skipped:   report.Count(smoketests.StatusSkip),
+		Time:      seconds(report.End.Sub(report.Start)),
+		Timestamp: report.Start.UTC().Format("2006-01-02T15:04:05"),
+	}
+
+	for _, result := range report.Results {
+		tc := junitTestCase{
+			Name:      result.Name,
+			Classname: junitSuiteName,
+			Time:      seconds(result.Duration),
+		}
+
+		out := []string{}
+		for _, warning := range result.Warnings {
+			out = append(out, "WARNING: "+warning)
+		}
+		out = append(out, result.Diagnostics...)
+		tc.SystemOut = strings.Join(out, "\n")
+
+		msg := &junitMessage{Message: result.Message, Text: result.Message}
+		switch result.Status {
+		case smoketests.StatusFail:
+			tc.Failure = msg
+		case smoketests.StatusError:
+			tc.Error = msg
+		case smoketests.StatusSkip:
+			tc.Skipped = &junitMessage{Message: result.Message}
+		}
+
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	suites := junitTestSuites{
+		Name:     junitSuiteName,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Skipped:  suite.Skipped,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,61 @@
+package output
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alex-leonhardt/kube-smoketest/pkg/smoketests"
+)
+
+// testReport returns a report with one result of each status
+func testReport() *smoketests.Report {
+	start := time.Date(2020, 5, 2, 19, 1, 48, 0, time.UTC)
+	report := &smoketests.Report{Start: start}
+	report.Add(
+		&smoketests.Result{Name: "Component statuses", Status: smoketests.StatusPass, Duration: time.Second},
+		&smoketests.Result{Name: "Deployment", Status: smoketests.StatusFail, Duration: 30 * time.Second, Message: "context deadline exceeded", Err: errors.New("context deadline exceeded")},
+		&smoketests.Result{Name: "Service", Status: smoketests.StatusSkip, Message: "skipped (dependency failed): Deployment"},
+		&smoketests.Result{Name: "Secret", Status: smoketests.StatusWarn, Duration: time.Second, Warnings: []string{"not encrypted at rest"}},
+		&smoketests.Result{Name: "Pod + Logs", Status: smoketests.StatusError, Message: "check panicked: boom"},
+	)
+	report.End = start.Add(51 * time.Second)
+	return report
+}
+
+func TestWriteJUnit(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := WriteJUnit(buf, testReport()); err != nil {
+		t.Fatalf("failed to write junit: %v", err)
+	}
+
+	suites := junitTestSuites{}
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatalf("failed to parse junit output: %v\n%s", err, buf.String())
+	}
+
+	if suites.Tests != 5 || suites.Failures != 1 || suites.Errors != 1 || suites.Skipped != 1 {
+		t.Errorf("unexpected totals: %+v", suites)
+	}
+	cases := suites.Suites[0].Cases
+	if len(cases) != 5 {
+		t.Fatalf("expected 5 test cases, got: %d", len(cases))
+	}
+	if cases[0].Failure != nil || cases[0].Skipped != nil || cases[0].Error != nil {
+		t.Errorf("expected %q to pass: %+v", cases[0].Name, cases[0])
+	}
+	if cases[1].Failure == nil || cases[1].Failure.Message != "context deadline exceeded" || cases[1].Time != "30.000" {
+		t.Errorf("expected %q to fail: %+v", cases[1].Name, cases[1])
+	}
+	if cases[2].Skipped == nil {
+		t.Errorf("expected %q to be skipped: %+v", cases[2].Name, cases[2])
+	}
+	if cases[3].Failure != nil || cases[3].SystemOut != "WARNING: not encrypted at rest" {
+		t.Errorf("expected %q to pass with a warning: %+v", cases[3].Name, cases[3])
+	}
+	if cases[4].Error == nil {
+		t.Errorf("expected %q to error: %+v", cases[4].Name, cases[4])
+	}
+}
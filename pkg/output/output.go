@@ -0,0 +1,99 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/alex-leonhardt/kube-smoketest/pkg/smoketests"
+	"github.com/hashicorp/go-multierror"
+)
+
+// ErrUnknownFormat is returned when an output format is requested that does not exist
+var ErrUnknownFormat = errors.New("unknown output format")
+
+// WriteFunc writes a report in a specific format
+type WriteFunc func(w io.Writer, report *smoketests.Report) error
+
+// formats are all supported output formats, by name
+var formats = map[string]WriteFunc{
+	"json":  WriteJSON,
+	"junit": WriteJUnit,
+}
+
+// Formats returns the names of all supported output formats
+func Formats() []string {
+	names := []string{}
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Output is a format and the path the report is written to, "-" means stdout
+type Output struct {
+	Format string
+	Path   string
+}
+
+// Write writes report to the output's path in the output's format
+func (o Output) Write(report *smoketests.Report) error {
+	write, ok := formats[o.Format]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownFormat, o.Format)
+	}
+
+	if o.Path == "" || o.Path == "-" {
+		return write(os.Stdout, report)
+	}
+
+	f, err := os.Create(o.Path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s output %s: %v", o.Format, o.Path, err)
+	}
+	if err = write(f, report); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s output %s: %v", o.Format, o.Path, err)
+	}
+	return f.Close()
+}
+
+// Flag is a flag.Value collecting outputs given as format=path, or just format to write to stdout,
+// the flag can be repeated to write several outputs
+type Flag []Output
+
+func (f *Flag) String() string {
+	outputs := []string{}
+	for _, o := range *f {
+		outputs = append(outputs, o.Format+"="+o.Path)
+	}
+	return strings.Join(outputs, ",")
+}
+
+// Set parses and adds a output
+func (f *Flag) Set(value string) error {
+	o := Output{Format: value, Path: "-"}
+	if i := strings.Index(value, "="); i >= 0 {
+		o.Format, o.Path = value[:i], value[i+1:]
+	}
+	if _, ok := formats[o.Format]; !ok {
+		return fmt.Errorf("%w: %q, must be one of %s", ErrUnknownFormat, o.Format, strings.Join(Formats(), ", "))
+	}
+	*f = append(*f, o)
+	return nil
+}
+
+// WriteAll writes report to all outputs, it returns the errors of all outputs that failed
+func (f Flag) WriteAll(report *smoketests.Report) error {
+	multierr := multierror.Error{}
+	for _, o := range f {
+		if err := o.Write(report); err != nil {
+			multierr.Errors = append(multierr.Errors, err)
+		}
+	}
+	return multierr.ErrorOrNil()
+}
@@ -0,0 +1,52 @@
+package output
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFlagSet(t *testing.T) {
+	f := Flag{}
+	if err := f.Set("junit=report.xml"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := f.Set("junit"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f[0].Format != "junit" || f[0].Path != "report.xml" || f[1].Path != "-" {
+		t.Errorf("unexpected outputs: %+v", f)
+	}
+
+	if err := f.Set("yaml=report.yaml"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("expected %v, got: %v", ErrUnknownFormat, err)
+	}
+}
+
+func TestWriteAll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kube-smoketest")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "report.xml")
+
+	f := Flag{}
+	if err := f.Set("junit=" + path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := f.WriteAll(testReport()); err != nil {
+		t.Fatalf("failed to write outputs: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if !strings.Contains(string(b), `<testcase name="Deployment"`) {
+		t.Errorf("expected output to contain the Deployment test case:\n%s", b)
+	}
+}
@@ -0,0 +1,35 @@
+// Package smoketests ... registers the built-in checks
+package smoketests
+
+import "time"
+
+// Names of the built-in checks, use these to depend on a built-in check
+const (
+	CheckComponentStatus = "Component statuses"
+	CheckNamespace       = "Create namespace"
+	CheckPodLogs         = "Pod + Logs"
+	CheckDeployment      = "Deployment"
+	CheckService         = "Service"
+	CheckNodePortService = "NodePort Service"
+	CheckSecret          = "Secret"
+	CheckSecretPod       = "Secret + Pod"
+	CheckStatefulSet     = "StatefulSet"
+	CheckPVC             = "PersistentVolumeClaim"
+	CheckConfigMap       = "ConfigMap"
+	// CheckDeleteNamespace is not registered, it runs after all other checks unless debugging
+	CheckDeleteNamespace = "Delete namespace"
+)
+
+func init() {
+	Register(WithTimeout(NewCheck(CheckComponentStatus, "verifies that essential control plane components are healthy", []string{TagCore}, ComponentStatus), 30*time.Second))
+	Register(WithTimeout(NewCheck(CheckNamespace, "creates the namespace all test resources are created in", []string{TagCore}, CreateNamespace, CheckComponentStatus), time.Minute))
+	Register(NewCheck(CheckPodLogs, "creates a pod, waits for it to run and retrieves its logs", []string{TagWorkload}, PodLogs, CheckNamespace))
+	Register(WithTimeout(NewCheck(CheckDeployment, "creates a nginx deployment and waits for its pods to become available", []string{TagWorkload}, CreateDeployment, CheckNamespace), 3*time.Minute))
+	Register(NewCheck(CheckService, "creates a ClusterIP service for the deployment and tests access from a job", []string{TagNetwork}, CreateService, CheckDeployment))
+	Register(WithTimeout(NewCheck(CheckNodePortService, "creates a NodePort service for the deployment and tests access via a node", []string{TagNetwork}, CreateNodePortService, CheckDeployment), time.Minute))
+	Register(WithTimeout(NewCheck(CheckSecret, "creates a secret and checks etcd whether it is encrypted at rest", []string{TagSecurity}, CreateSecret, CheckNamespace), time.Minute))
+	Register(NewCheck(CheckSecretPod, "creates a secret, mounts it into a pod and reads it from a volume and an environment variable", []string{TagSecurity, TagWorkload}, SecretPod, CheckNamespace))
+	Register(WithTimeout(NewCheck(CheckStatefulSet, "creates a statefulset and verifies ordered rollout, stable pod names and volumes, and ordered scale down", []string{TagWorkload, TagStorage}, CreateStatefulSet, CheckNamespace), 5*time.Minute))
+	Register(WithTimeout(NewCheck(CheckPVC, "provisions a volume, writes to it and reads it back from another pod, and verifies it is reclaimed", []string{TagStorage}, CreatePVC, CheckNamespace), 5*time.Minute))
+	Register(WithTimeout(NewCheck(CheckConfigMap, "creates a configmap, reads it from a pod and measures how long an update takes to reach the pod", []string{TagWorkload}, CreateConfigMap, CheckNamespace), 4*time.Minute))
+}
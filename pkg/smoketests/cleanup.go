@@ -0,0 +1,93 @@
+// Package smoketests ... every object a run creates is tracked, so it can be removed no matter how the run ended
+package smoketests
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/hashicorp/go-multierror"
+)
+
+// Cleanup tracks the objects created during a run, see WithCleanup
+type Cleanup struct {
+	mu      sync.Mutex
+	objects []object
+}
+
+// NewCleanup returns a Cleanup not tracking any objects yet
+func NewCleanup() *Cleanup {
+	return &Cleanup{}
+}
+
+// cleanupKey is the context key of the Cleanup of a run
+type cleanupKey struct{}
+
+// WithCleanup returns a copy of ctx that checks register the objects they create in with c
+func WithCleanup(ctx context.Context, c *Cleanup) context.Context {
+	return context.WithValue(ctx, cleanupKey{}, c)
+}
+
+// track registers obj with the Cleanup of ctx, if any, once obj was created
+func track(ctx context.Context, obj object) {
+	c, _ := ctx.Value(cleanupKey{}).(*Cleanup)
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.objects = append(c.objects, obj)
+}
+
+// Objects returns the objects tracked, in the order they were created, e.g. "deployment kube-smoketest-0123456789ab/smoketest"
+func (c *Cleanup) Objects() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	objects := []string{}
+	for _, obj := range c.objects {
+		objects = append(objects, obj.String())
+	}
+	return objects
+}
+
+// Run removes all tracked objects in the reverse order they were created, and waits until they're gone;
+// objects in a tracked namespace are removed with their namespace. The error lists every object that could
+// not be removed, objects removed are no longer tracked.
+func (c *Cleanup) Run(ctx context.Context) error {
+	c.mu.Lock()
+	objects := c.objects
+	c.objects = nil
+	c.mu.Unlock()
+
+	namespaces := map[string]bool{}
+	for _, obj := range objects {
+		if obj.kind == "namespace" {
+			namespaces[obj.name] = true
+		}
+	}
+
+	multierr := multierror.Error{}
+	leftovers := []object{}
+	for i := len(objects) - 1; i >= 0; i-- {
+		obj := objects[i]
+		if namespaces[obj.namespace] {
+			continue
+		}
+
+		glog.V(2).Infof("removing %s", obj)
+		if err := remove(ctx, obj); err != nil {
+			multierr.Errors = append(multierr.Errors, fmt.Errorf("failed to remove %s: %w", obj, err))
+			leftovers = append([]object{obj}, leftovers...)
+		}
+	}
+
+	// keep tracking what could not be removed, so another attempt can be made
+	c.mu.Lock()
+	c.objects = append(leftovers, c.objects...)
+	c.mu.Unlock()
+
+	return multierr.ErrorOrNil()
+}
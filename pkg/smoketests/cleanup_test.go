@@ -0,0 +1,84 @@
+package smoketests
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCleanup(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset()
+	deploymentsBecomeAvailable(client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cleanup := NewCleanup()
+	ctx = WithCleanup(ctx, cleanup)
+
+	if err := CreateNamespace(ctx, client, testNamespace); err != nil {
+		t.Fatalf("failed to create namespace: %v", err)
+	}
+	if err := CreateDeployment(ctx, client, testNamespace); err != nil {
+		t.Fatalf("failed to create deployment: %v", err)
+	}
+	// e.g. a check creating an object outside of the run's namespace
+	if err := CreateDeployment(ctx, client, "elsewhere"); err != nil {
+		t.Fatalf("failed to create deployment: %v", err)
+	}
+
+	if objects := cleanup.Objects(); len(objects) != 3 {
+		t.Fatalf("expected 3 objects to be tracked, got: %v", objects)
+	}
+
+	if err := cleanup.Run(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if objects := cleanup.Objects(); len(objects) != 0 {
+		t.Errorf("expected nothing to be tracked after the cleanup, got: %v", objects)
+	}
+
+	if _, err := client.CoreV1().Namespaces().Get(ctx, testNamespace, metav1.GetOptions{}); err == nil {
+		t.Error("expected the namespace to be deleted")
+	}
+	if _, err := client.AppsV1().Deployments("elsewhere").Get(ctx, cfg.Deployment.Name, metav1.GetOptions{}); err == nil {
+		t.Error("expected the deployment outside of the namespace to be deleted")
+	}
+	// the deployment in the namespace goes with it
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "delete" && action.GetNamespace() == testNamespace {
+			t.Errorf("expected nothing in the namespace to be deleted separately, got: %s %s", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+}
+
+func TestCleanupLeftovers(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(&v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "stuck", Namespace: "elsewhere"}})
+	client.PrependReactor("delete", "services", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("admission webhook denied the request")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cleanup := NewCleanup()
+	track(WithCleanup(ctx, cleanup), serviceObject(client, "elsewhere", "stuck"))
+
+	err := cleanup.Run(ctx)
+	if err == nil || !strings.Contains(err.Error(), "service elsewhere/stuck") {
+		t.Fatalf("expected the service that could not be removed to be reported, got: %v", err)
+	}
+	if objects := cleanup.Objects(); len(objects) != 1 {
+		t.Errorf("expected the service to still be tracked, got: %v", objects)
+	}
+}
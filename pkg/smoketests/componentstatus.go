@@ -10,12 +10,13 @@ import (
 )
 
 // ComponentStatus checks for control plane components
-func ComponentStatus(ctx context.Context, client *kubernetes.Clientset) error {
+func ComponentStatus(ctx context.Context, client kubernetes.Interface, namespace string) error {
 	multierr := multierror.Error{}
 
 	statuses, err := client.CoreV1().ComponentStatuses().List(ctx, metav1.ListOptions{})
 	if err != nil {
 		multierr.Errors = append(multierr.Errors, err)
+		return multierr.ErrorOrNil()
 	}
 	for _, status := range statuses.Items {
 		for _, cond := range status.Conditions {
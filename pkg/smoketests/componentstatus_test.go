@@ -0,0 +1,24 @@
+package smoketests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestComponentStatusAPIError(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("list", "componentstatuses", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("forbidden")
+	})
+
+	if err := ComponentStatus(context.Background(), client, testNamespace); err == nil {
+		t.Fatal("expected an error when componentstatuses cannot be listed")
+	}
+}
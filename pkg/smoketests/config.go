@@ -0,0 +1,26 @@
+// Package smoketests ... the configuration used by the checks
+package smoketests
+
+import (
+	"github.com/alex-leonhardt/kube-smoketest/pkg/config"
+)
+
+// cfg is the configuration used by the checks
+var cfg = config.Default()
+
+// Configure sets the configuration used by the checks, it must be called before running them
+func Configure(c *config.Config) {
+	cfg = c
+}
+
+// objectLabels returns the labels configured for all objects, merged with labels
+func objectLabels(labels map[string]string) map[string]string {
+	merged := map[string]string{}
+	for k, v := range cfg.Labels {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,103 @@
+package smoketests
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alex-leonhardt/kube-smoketest/pkg/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// configure makes the checks use the defaults changed by fn until the test finished
+func configure(t *testing.T, fn func(c *config.Config)) {
+	orig := cfg
+	c := config.Default()
+	fn(c)
+	Configure(c)
+	t.Cleanup(func() { Configure(orig) })
+}
+
+func disable(c *config.Config, names ...string) {
+	c.Checks = map[string]config.Check{}
+	disabled := false
+	for _, name := range names {
+		c.Checks[name] = config.Check{Enabled: &disabled}
+	}
+}
+
+func TestEnabledChecks(t *testing.T) {
+	configure(t, func(c *config.Config) { disable(c, CheckService, CheckNodePortService) })
+
+	checks, err := EnabledChecks()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(checks) != len(Checks())-2 {
+		t.Errorf("expected all but 2 checks, got: %d of %d", len(checks), len(Checks()))
+	}
+	for _, c := range checks {
+		if c.Name() == CheckService || c.Name() == CheckNodePortService {
+			t.Errorf("expected %q to be disabled", c.Name())
+		}
+	}
+}
+
+func TestEnabledChecksDependencyDisabled(t *testing.T) {
+	configure(t, func(c *config.Config) { disable(c, CheckDeployment) })
+
+	_, err := EnabledChecks()
+	if err == nil || !strings.Contains(err.Error(), CheckDeployment) {
+		t.Fatalf("expected an error about the disabled dependency, got: %v", err)
+	}
+}
+
+func TestConfiguredDeployment(t *testing.T) {
+	configure(t, func(c *config.Config) {
+		c.Labels = map[string]string{"team": "platform"}
+		c.Images.Deployment = "registry.example.com/nginx"
+		c.Deployment.Name = "web"
+		c.Deployment.Replicas = 1
+	})
+
+	client := fake.NewSimpleClientset()
+	deploymentsBecomeAvailable(client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := CreateDeployment(ctx, client, "configured"); err != nil {
+		t.Fatalf("failed to create deployment: %v", err)
+	}
+
+	deploy, err := client.AppsV1().Deployments("configured").Get(ctx, "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the configured deployment to exist: %v", err)
+	}
+	if *deploy.Spec.Replicas != 1 {
+		t.Errorf("expected 1 replica, got: %d", *deploy.Spec.Replicas)
+	}
+	if image := deploy.Spec.Template.Spec.Containers[0].Image; image != "registry.example.com/nginx" {
+		t.Errorf("expected the configured image, got: %q", image)
+	}
+	if deploy.Labels["team"] != "platform" || deploy.Spec.Template.Labels["team"] != "platform" {
+		t.Errorf("expected the configured labels, got: %v and %v", deploy.Labels, deploy.Spec.Template.Labels)
+	}
+}
+
+func TestEnabledChecksRunTimeout(t *testing.T) {
+	// component statuses 30s, namespace 1m and statefulset 5m, or deployment 3m and service 2m
+	configure(t, func(c *config.Config) { c.Timeouts.Run = metav1.Duration{Duration: 6*time.Minute + 30*time.Second} })
+
+	_, err := EnabledChecks()
+	if err == nil || !strings.Contains(err.Error(), "must be greater than 6m30s") {
+		t.Fatalf("expected an error about the run timeout, got: %v", err)
+	}
+
+	configure(t, func(c *config.Config) { c.Timeouts.Run = metav1.Duration{Duration: 7 * time.Minute} })
+	if _, err := EnabledChecks(); err != nil {
+		t.Fatalf("expected the run timeout to be enough, got: %v", err)
+	}
+}
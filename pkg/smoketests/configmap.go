@@ -0,0 +1,189 @@
+// Package smoketests ... creates a configmap, confirms that pods can consume it and see it change
+package smoketests
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/uuid"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// configMapKey is the key of the configmap's value, it's an environment variable of the pod too
+const configMapKey = "SMOKETEST_VALUE"
+
+// Where the configmap pod reads the value from, and logs it as
+const (
+	// fromEnv is the environment variable set by envFrom
+	fromEnv = "env"
+	// fromSubPath is the key mounted with subPath, it's never updated
+	fromSubPath = "subpath"
+	// fromVolume is the key in the projected volume, it's updated by the kubelet
+	fromVolume = "volume"
+)
+
+// CreateConfigMap creates a configmap, and a pod consuming it via envFrom, a projected volume and a subPath mount;
+// it verifies that the pod reads the configmap's value from all three, then updates the configmap and returns an
+// error unless the kubelet updates the volume within the configured propagation budget
+func CreateConfigMap(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	name := cfg.ConfigMap.Name
+	podName := "configmap"
+
+	nonce := uuid.New().String()
+	created, updated := "created-"+nonce, "updated-"+nonce
+
+	glog.V(2).Infof("creating configmap %s", name)
+	configMap := &v1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: objectLabels(map[string]string{
+				"testName": "configmap",
+			}),
+		},
+		Data: map[string]string{configMapKey: created},
+	}
+	if _, err := client.CoreV1().ConfigMaps(namespace).Create(ctx, configMap, metav1.CreateOptions{}); err != nil {
+		glog.Errorf("failed to create configmap %s: %v", name, err)
+		return err
+	}
+	track(ctx, configMapObject(client, namespace))
+
+	volume := v1.Volume{
+		Name: "config",
+		VolumeSource: v1.VolumeSource{
+			Projected: &v1.ProjectedVolumeSource{
+				Sources: []v1.VolumeProjection{
+					{ConfigMap: &v1.ConfigMapProjection{LocalObjectReference: v1.LocalObjectReference{Name: name}}},
+				},
+			},
+		},
+	}
+	// every line has all values, only the last lines of the logs are read
+	script := fmt.Sprintf("trap 'exit' SIGTERM SIGINT; while true; do echo \"%s=$%s %s=$(cat /subpath/%s) %s=$(cat /config/%s)\"; sleep 1; done",
+		fromEnv, configMapKey, fromSubPath, configMapKey, fromVolume, configMapKey)
+
+	pod, err := CreatePod(ctx, client, namespace, podName, "", []string{"/bin/sh", "-c"}, []string{script},
+		WithEnvFrom(v1.EnvFromSource{ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: name}}}),
+		WithVolume(volume, "/config"),
+		WithVolumeMount(v1.VolumeMount{Name: volume.Name, MountPath: "/subpath/" + configMapKey, SubPath: configMapKey}))
+	if err != nil {
+		return err
+	}
+	if err := WaitFor(ctx, client, Pod, WithNamespace(namespace), WithPodName(pod.Name)); err != nil {
+		return err
+	}
+
+	values, err := waitConfigMapValues(ctx, client, namespace, pod.Name, func(values map[string]string) bool { return true })
+	if err != nil {
+		return err
+	}
+	for _, source := range []string{fromEnv, fromSubPath, fromVolume} {
+		if values[source] != created {
+			return fmt.Errorf("pod %s read %q from the %s of configmap %s, expected %q", pod.Name, values[source], source, name, created)
+		}
+	}
+	glog.V(2).Infof("pod %s read configmap %s from env, subpath and volume", pod.Name, name)
+
+	return TestConfigMapUpdate(ctx, client, namespace, pod.Name, updated)
+}
+
+// TestConfigMapUpdate sets the configmap's value to value, and returns an error unless the pod called podName
+// reads it from the configmap's volume within the configured propagation budget
+func TestConfigMapUpdate(ctx context.Context, client kubernetes.Interface, namespace, podName, value string) error {
+	name := cfg.ConfigMap.Name
+	budget := cfg.ConfigMap.PropagationBudget.Duration
+	configMaps := client.CoreV1().ConfigMaps(namespace)
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		configMap.Data[configMapKey] = value
+		_, err = configMaps.Update(ctx, configMap, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update configmap %s: %w", name, err)
+	}
+	start := time.Now()
+
+	waitCtx, cancel := context.WithTimeout(ctx, budget)
+	defer cancel()
+	values, err := waitConfigMapValues(waitCtx, client, namespace, podName, func(values map[string]string) bool {
+		return values[fromVolume] == value
+	})
+	if err != nil && ctx.Err() == nil && waitCtx.Err() != nil {
+		return fmt.Errorf("configmap %s was updated, but pod %s still reads %q from its volume after the propagation budget of %v",
+			name, podName, values[fromVolume], budget)
+	}
+	if err != nil {
+		return err
+	}
+
+	reached := time.Now()
+	recordWait(ctx, Wait{Resource: "configmaps", Phase: fmt.Sprintf("waiting for the update of configmap %s to propagate to pod %s", name, podName), Start: start, Reached: reached})
+	glog.V(2).Infof("the update of configmap %s took %v to propagate to pod %s", name, reached.Sub(start), podName)
+	return nil
+}
+
+// configMapValues returns the values the configmap pod logged last, by where it read them from, or nil if it
+// didn't log any yet
+func configMapValues(ctx context.Context, client kubernetes.Interface, namespace, podName string) (map[string]string, error) {
+	output, err := GetPodLogs(ctx, client, namespace, podName)
+	if err != nil {
+		return nil, err
+	}
+	return loggedValues(output), nil
+}
+
+// waitConfigMapValues polls the logs of the configmap pod until it logged values that done returns true for,
+// it returns the values logged last
+func waitConfigMapValues(ctx context.Context, client kubernetes.Interface, namespace, podName string, done func(values map[string]string) bool) (map[string]string, error) {
+	values := map[string]string{}
+	for {
+		read, err := configMapValues(ctx, client, namespace, podName)
+		if err != nil && ctx.Err() == nil {
+			return values, err
+		}
+		if read != nil {
+			values = read
+			if done(values) {
+				return values, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return values, fmt.Errorf("waiting for pod %s to read configmap %s, it read %v: %w", podName, cfg.ConfigMap.Name, values, ctx.Err())
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// configMapObject is the configmap created by CreateConfigMap
+func configMapObject(client kubernetes.Interface, namespace string) object {
+	configMaps := client.CoreV1().ConfigMaps(namespace)
+	name := cfg.ConfigMap.Name
+	return object{
+		kind:      "configmap",
+		namespace: namespace,
+		name:      name,
+		get: func(ctx context.Context) error {
+			_, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+			return err
+		},
+		delete: func(ctx context.Context) error {
+			return configMaps.Delete(ctx, name, metav1.DeleteOptions{})
+		},
+	}
+}
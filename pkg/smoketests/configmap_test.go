@@ -0,0 +1,100 @@
+package smoketests
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alex-leonhardt/kube-smoketest/pkg/config"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// configMapsMounted makes the configmap pod created through client run and log what it reads from the
+// configmap: the value it was created with from env and subpath, and from the volume its current value if
+// propagate is true, the value it was created with otherwise; the logs of other pods are not stubbed
+func configMapsMounted(t *testing.T, client *fake.Clientset, propagate bool) {
+	podsStartRunning(client)
+
+	mu := sync.Mutex{}
+	created := ""
+	client.PrependReactor("create", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		created = action.(k8stesting.CreateAction).GetObject().(*v1.ConfigMap).Data[configMapKey]
+		return false, nil, nil
+	})
+
+	orig := podLogStream
+	podLogStream = func(ctx context.Context, client kubernetes.Interface, namespace, podName string, opts *v1.PodLogOptions) (io.ReadCloser, error) {
+		if podName != "configmap" {
+			return orig(ctx, client, namespace, podName, opts)
+		}
+		configMap, err := client.CoreV1().ConfigMaps(namespace).Get(ctx, cfg.ConfigMap.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		volume := created
+		if propagate {
+			volume = configMap.Data[configMapKey]
+		}
+		return ioutil.NopCloser(strings.NewReader(fmt.Sprintf("env=%s subpath=%s volume=%s\n", created, created, volume))), nil
+	}
+	t.Cleanup(func() { podLogStream = orig })
+}
+
+func TestCreateConfigMap(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	configMapsMounted(t, client, true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	result := NewResult(NewCheck(CheckConfigMap, "", nil, nil))
+
+	if err := CreateConfigMap(withResult(ctx, result), client, testNamespace); err != nil {
+		t.Fatalf("expected configmap test to succeed, got: %v", err)
+	}
+
+	pod, err := client.CoreV1().Pods(testNamespace).Get(ctx, "configmap", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the configmap pod to be created: %v", err)
+	}
+	if c := pod.Spec.Containers[0]; len(c.EnvFrom) != 1 || len(c.VolumeMounts) != 2 || c.VolumeMounts[1].SubPath != configMapKey {
+		t.Errorf("expected the pod to consume the configmap via envFrom, a volume and a subPath, got: %+v", c)
+	}
+
+	waits := []string{}
+	for _, w := range result.Waits {
+		waits = append(waits, w.Resource)
+	}
+	if strings.Join(waits, ",") != "pods,configmaps" {
+		t.Errorf("expected the waits for the pod and the configmap update to be recorded, got: %v", waits)
+	}
+}
+
+func TestCreateConfigMapPropagationBudget(t *testing.T) {
+	configure(t, func(c *config.Config) { c.ConfigMap.PropagationBudget = metav1.Duration{Duration: 2 * time.Second} })
+
+	client := fake.NewSimpleClientset()
+	configMapsMounted(t, client, false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := CreateConfigMap(ctx, client, testNamespace)
+	if err == nil || !strings.Contains(err.Error(), "after the propagation budget of 2s") {
+		t.Fatalf("expected the propagation budget to be exceeded, got: %v", err)
+	}
+}
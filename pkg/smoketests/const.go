@@ -1,11 +1,4 @@
 package smoketests
 
-const namespace = "kube-smoketest"
-const serviceName = "smoketest-service"
-const serviceNameNodePort = "smoketest-service-np"
-
-const secretName = "smoketest-secret"
+// secretValue is the value of the secret's "user" key, the API encodes it
 const secretValue = "admin"
-const secretValueBase64 = "YWRtaW4K"
-
-const etcdPort = 2379
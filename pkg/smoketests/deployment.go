@@ -11,28 +11,22 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
-// CreateDeployment creates a dummy nginx deployment of 2 pods
-func CreateDeployment(ctx context.Context, client *kubernetes.Clientset) error {
-	deploy, err := client.AppsV1().Deployments(namespace).Get(ctx, "smoketest", metav1.GetOptions{})
-	if err == nil {
-		glog.V(2).Infof("using existing deployment: %#v", deploy.ObjectMeta.Name)
-		return nil
-	}
+// CreateDeployment creates a dummy deployment of the configured image and number of replicas
+func CreateDeployment(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	numReplicas := cfg.Deployment.Replicas
 
 	glog.V(2).Infoln("creating deployment")
 
-	numReplicas := int32(2)
-
 	deployment := &appsv1.Deployment{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "apps/v1",
 			Kind:       "deployment",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "smoketest",
-			Labels: map[string]string{
+			Name: cfg.Deployment.Name,
+			Labels: objectLabels(map[string]string{
 				"testName": "deployment",
-			},
+			}),
 		},
 		Spec: appsv1.DeploymentSpec{
 			Replicas: &numReplicas,
@@ -41,18 +35,18 @@ func CreateDeployment(ctx context.Context, client *kubernetes.Clientset) error {
 					"app": "smoketest",
 				},
 			},
-			MinReadySeconds: int32(7),
+			MinReadySeconds: cfg.Deployment.MinReadySeconds,
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Name: "nginx",
-					Labels: map[string]string{
+					Labels: objectLabels(map[string]string{
 						"app": "smoketest",
-					},
+					}),
 				},
 				Spec: corev1.PodSpec{
 					Containers: []corev1.Container{
 						corev1.Container{
-							Image: "nginx",
+							Image: cfg.Images.Deployment,
 							Name:  "webserver",
 						},
 					},
@@ -61,13 +55,14 @@ func CreateDeployment(ctx context.Context, client *kubernetes.Clientset) error {
 		},
 	}
 
-	deploy, err = client.AppsV1().Deployments(namespace).Create(ctx, deployment, metav1.CreateOptions{})
+	deploy, err := client.AppsV1().Deployments(namespace).Create(ctx, deployment, metav1.CreateOptions{})
 	if err != nil {
 		glog.Errorf("failed to create deployment: %v", err)
 		return err
 	}
+	track(ctx, deploymentObject(client, namespace))
 
-	if err = WaitFor(ctx, client, Deployment, WithNumReady(numReplicas)); err != nil {
+	if err = WaitFor(ctx, client, Deployment, WithNamespace(namespace), WithNumReady(numReplicas)); err != nil {
 		glog.Warningf("failed to create deployment: %v", err)
 		return err
 	}
@@ -76,9 +71,26 @@ func CreateDeployment(ctx context.Context, client *kubernetes.Clientset) error {
 	return nil
 }
 
+// deploymentObject is the deployment created by CreateDeployment
+func deploymentObject(client kubernetes.Interface, namespace string) object {
+	deployments := client.AppsV1().Deployments(namespace)
+	return object{
+		kind:      "deployment",
+		namespace: namespace,
+		name:      cfg.Deployment.Name,
+		get: func(ctx context.Context) error {
+			_, err := deployments.Get(ctx, cfg.Deployment.Name, metav1.GetOptions{})
+			return err
+		},
+		delete: func(ctx context.Context) error {
+			return deployments.Delete(ctx, cfg.Deployment.Name, metav1.DeleteOptions{})
+		},
+	}
+}
+
 // DeleteDeployment deletes the deployment ..
-func DeleteDeployment(ctx context.Context, client *kubernetes.Clientset) error {
-	if err := client.AppsV1().Deployments(namespace).Delete(ctx, "smoketest", metav1.DeleteOptions{}); err != nil {
+func DeleteDeployment(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	if err := deploymentObject(client, namespace).delete(ctx); err != nil {
 		glog.Errorf("failed to delete deployment: %v", err)
 		return err
 	}
@@ -0,0 +1,54 @@
+package smoketests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// deploymentsBecomeAvailable makes all replicas of every deployment created through client available
+func deploymentsBecomeAvailable(client *fake.Clientset) {
+	client.PrependReactor("create", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		deployment := action.(k8stesting.CreateAction).GetObject().(*appsv1.Deployment)
+		deployment.Status.AvailableReplicas = *deployment.Spec.Replicas
+		return false, nil, nil
+	})
+}
+
+func TestCreateDeployment(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset()
+	deploymentsBecomeAvailable(client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := CreateDeployment(ctx, client, testNamespace); err != nil {
+		t.Fatalf("expected deployment to become available, got: %v", err)
+	}
+
+	if _, err := client.AppsV1().Deployments(testNamespace).Get(ctx, cfg.Deployment.Name, metav1.GetOptions{}); err != nil {
+		t.Fatalf("expected deployment to be created: %v", err)
+	}
+}
+
+func TestCreateDeploymentAPIError(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("admission webhook denied the request")
+	})
+
+	if err := CreateDeployment(context.Background(), client, testNamespace); err == nil {
+		t.Fatal("expected an error when the deployment cannot be created")
+	}
+}
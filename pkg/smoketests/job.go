@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/google/uuid"
@@ -16,8 +17,8 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
-// CreateJob ... creates a k8s job, runs the command and exits
-func CreateJob(ctx context.Context, client *kubernetes.Clientset, arg string) (*v1.Job, error) {
+// CreateJob ... creates a k8s job in namespace, runs the command and exits
+func CreateJob(ctx context.Context, client kubernetes.Interface, namespace, arg string) (*v1.Job, error) {
 
 	uuid, err := uuid.NewUUID()
 	uuids := strings.Split(fmt.Sprintf("%s", uuid), "-")
@@ -31,19 +32,21 @@ func CreateJob(ctx context.Context, client *kubernetes.Clientset, arg string) (*
 			Kind:       "Batch",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name: jobName,
+			Name:   jobName,
+			Labels: objectLabels(nil),
 		},
 		Spec: v1.JobSpec{
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Name: "tester",
+					Name:   "tester",
+					Labels: objectLabels(nil),
 				},
 				Spec: corev1.PodSpec{
 					RestartPolicy: corev1.RestartPolicyNever,
 					Containers: []corev1.Container{
 						corev1.Container{
 							Name:    "box",
-							Image:   "busybox",
+							Image:   cfg.Images.Job,
 							Command: []string{"/bin/sh", "-c"},
 							Args:    []string{arg},
 						},
@@ -58,8 +61,61 @@ func CreateJob(ctx context.Context, client *kubernetes.Clientset, arg string) (*
 		glog.Errorf("failed to create job %s: %v", jobName, err)
 		return nil, err
 	}
+	track(ctx, jobObject(client, namespace, jobName))
 
 	glog.V(2).Infof("successfully created job %s", jobName)
 
 	return job, nil
 }
+
+// jobOutput runs arg in a job, waits for its pod to complete and returns the pod's logs
+func jobOutput(ctx context.Context, client kubernetes.Interface, namespace, arg string) ([]string, error) {
+	job, err := CreateJob(ctx, client, namespace, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	// quick loop as it may take a few seconds for Pods to be scheduled and created
+	var pods *corev1.PodList
+	for maxTries := 3; ; maxTries-- {
+		pods, err = client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("job-name=%s", job.GetName()),
+		})
+		if err == nil && len(pods.Items) > 0 {
+			break
+		}
+		if maxTries < 1 {
+			if err == nil {
+				err = fmt.Errorf("no pods found for job %s", job.GetName())
+			}
+			return nil, err
+		}
+		time.Sleep(time.Second)
+	}
+
+	pod := pods.Items[0] // no need to guess which pod, as we should only have one that matches the label
+
+	if err = WaitFor(ctx, client, Pod, WithNamespace(namespace), WithPodName(pod.Name), WithStatus(PodCompleted)); err != nil {
+		return nil, err
+	}
+
+	return GetPodLogs(ctx, client, namespace, pod.Name)
+}
+
+// jobObject is a job created by CreateJob, deleting it deletes its pods too
+func jobObject(client kubernetes.Interface, namespace, name string) object {
+	jobs := client.BatchV1().Jobs(namespace)
+	propagation := metav1.DeletePropagationBackground
+	return object{
+		kind:      "job",
+		namespace: namespace,
+		name:      name,
+		get: func(ctx context.Context) error {
+			_, err := jobs.Get(ctx, name, metav1.GetOptions{})
+			return err
+		},
+		delete: func(ctx context.Context) error {
+			return jobs.Delete(ctx, name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+		},
+	}
+}
@@ -2,37 +2,87 @@ package smoketests
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/golang/glog"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 
 	"k8s.io/client-go/kubernetes"
 )
 
-// CreateNamespace creates the kube-smoketest namespace
-func CreateNamespace(ctx context.Context, client *kubernetes.Clientset) error {
+// Labels set on the namespace of every run
+const (
+	LabelManagedBy = "app.kubernetes.io/managed-by"
+	LabelRunID     = "kube-smoketest/run-id"
+	LabelUser      = "kube-smoketest/user"
+	LabelCreated   = "kube-smoketest/created"
+)
+
+// ManagedBy is the value of LabelManagedBy on all namespaces created by kube-smoketest
+const ManagedBy = "kube-smoketest"
+
+// NamespaceName returns the name of the namespace of run runID, the configured prefix followed by the run id
+func NamespaceName(runID string) string {
+	return cfg.Namespace.Prefix + "-" + runID
+}
+
+// invalidLabelChars are the characters not allowed in label values
+var invalidLabelChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// currentUser returns the name of the user running the smoketests as a valid label value
+func currentUser() string {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		name = u.Username
+	}
+
+	name = invalidLabelChars.ReplaceAllString(name, "_")
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return strings.Trim(name, "_.-")
+}
+
+// CreateNamespace creates namespace, labeled with the run id, the user and the time it was created
+func CreateNamespace(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	labels := map[string]string{}
+	for k, v := range cfg.Namespace.Labels {
+		labels[k] = v
+	}
+	labels[LabelManagedBy] = ManagedBy
+	labels[LabelCreated] = strconv.FormatInt(time.Now().Unix(), 10)
+	if runID := RunID(ctx); runID != "" {
+		labels[LabelRunID] = runID
+	}
+	if user := currentUser(); user != "" {
+		labels[LabelUser] = user
+	}
+
 	ns := &v1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: namespace,
+			Name:   namespace,
+			Labels: objectLabels(labels),
 		},
 	}
 
-	// get our namespace and if it already exists, then we'll return early
-	nsget, err := client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
-	if err == nil {
-		glog.V(2).Infof("namespace %s exists, not creating; found: %#v", namespace, nsget)
-		return nil
-	}
-
 	opts := metav1.CreateOptions{}
-	ns, err = client.CoreV1().Namespaces().Create(ctx, ns, opts)
+	ns, err := client.CoreV1().Namespaces().Create(ctx, ns, opts)
 	if err != nil {
 		glog.Errorf("failed to create namespace %s: %v", namespace, err.Error())
 		return err
 	}
+	track(ctx, namespaceObject(client, namespace))
 
-	if err = WaitFor(ctx, client, Namespace); err != nil {
+	if err = WaitFor(ctx, client, Namespace, WithNamespace(namespace)); err != nil {
 		glog.Errorf("failed to create namespace %s: %v", namespace, err.Error())
 		return err
 	}
@@ -41,10 +91,29 @@ func CreateNamespace(ctx context.Context, client *kubernetes.Clientset) error {
 	return nil
 }
 
-// DeleteNamespace deletes the test namespace
-func DeleteNamespace(ctx context.Context, client *kubernetes.Clientset) error {
-	opts := metav1.DeleteOptions{}
-	err := client.CoreV1().Namespaces().Delete(ctx, namespace, opts)
+// namespaceObject is the namespace created by CreateNamespace
+func namespaceObject(client kubernetes.Interface, namespace string) object {
+	namespaces := client.CoreV1().Namespaces()
+	return object{
+		kind: "namespace",
+		name: namespace,
+		get: func(ctx context.Context) error {
+			_, err := namespaces.Get(ctx, namespace, metav1.GetOptions{})
+			return err
+		},
+		delete: func(ctx context.Context) error {
+			return namespaces.Delete(ctx, namespace, metav1.DeleteOptions{})
+		},
+		wait: func(ctx context.Context) error {
+			return WaitFor(ctx, client, Namespace, WithNamespace(namespace), WithDeleted())
+		},
+	}
+}
+
+// DeleteNamespace deletes the test namespace and waits until it's gone, a namespace stuck terminating
+// returns a *NamespaceStuckError
+func DeleteNamespace(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	err := remove(ctx, namespaceObject(client, namespace))
 	if err != nil {
 		glog.Errorf("failed to delete namespace %s: %v", namespace, err.Error())
 		return err
@@ -53,3 +122,79 @@ func DeleteNamespace(ctx context.Context, client *kubernetes.Clientset) error {
 	glog.V(2).Infof("namespace %v deleted", namespace)
 	return nil
 }
+
+// namespaceStuck returns why namespace was not deleted before WaitFor timed out, it gets the namespace's
+// deletion conditions and the objects in it with finalizers with a fresh timeout, as the one of WaitFor is over
+func namespaceStuck(client kubernetes.Interface, namespace string, timeout *TimeoutError) *NamespaceStuckError {
+	stuck := &NamespaceStuckError{Namespace: namespace, Timeout: timeout}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ns, err := client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		glog.Warningf("failed to get namespace %s: %v", namespace, err)
+		return stuck
+	}
+	for _, c := range ns.Status.Conditions {
+		if c.Status != v1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case v1.NamespaceDeletionContentFailure, v1.NamespaceFinalizersRemaining, v1.NamespaceContentRemaining,
+			v1.NamespaceDeletionDiscoveryFailure, v1.NamespaceDeletionGVParsingFailure:
+			stuck.Conditions = append(stuck.Conditions, fmt.Sprintf("%s: %s", c.Type, c.Message))
+		}
+	}
+	if len(ns.Spec.Finalizers) > 0 {
+		stuck.Finalizers = append(stuck.Finalizers, fmt.Sprintf("namespace %s (%s)", namespace, finalizerList(ns.Spec.Finalizers)))
+	}
+
+	// the objects the checks create, e.g. claims keep their kubernetes.io/pvc-protection finalizer while a pod uses them
+	opts := metav1.ListOptions{}
+	lists := []struct {
+		kind string
+		list func() (runtime.Object, error)
+	}{
+		{"pod", func() (runtime.Object, error) { return client.CoreV1().Pods(namespace).List(ctx, opts) }},
+		{"service", func() (runtime.Object, error) { return client.CoreV1().Services(namespace).List(ctx, opts) }},
+		{"secret", func() (runtime.Object, error) { return client.CoreV1().Secrets(namespace).List(ctx, opts) }},
+		{"configmap", func() (runtime.Object, error) { return client.CoreV1().ConfigMaps(namespace).List(ctx, opts) }},
+		{"persistentvolumeclaim", func() (runtime.Object, error) {
+			return client.CoreV1().PersistentVolumeClaims(namespace).List(ctx, opts)
+		}},
+		{"deployment", func() (runtime.Object, error) { return client.AppsV1().Deployments(namespace).List(ctx, opts) }},
+		{"statefulset", func() (runtime.Object, error) { return client.AppsV1().StatefulSets(namespace).List(ctx, opts) }},
+		{"job", func() (runtime.Object, error) { return client.BatchV1().Jobs(namespace).List(ctx, opts) }},
+	}
+	for _, l := range lists {
+		list, err := l.list()
+		if err != nil {
+			glog.Warningf("failed to list %ss in namespace %s: %v", l.kind, namespace, err)
+			continue
+		}
+		meta.EachListItem(list, func(item runtime.Object) error {
+			obj, err := meta.Accessor(item)
+			if err != nil {
+				return err
+			}
+			if finalizers := obj.GetFinalizers(); len(finalizers) > 0 {
+				stuck.Finalizers = append(stuck.Finalizers, fmt.Sprintf("%s %s (%s)", l.kind, obj.GetName(), strings.Join(finalizers, ", ")))
+			} else {
+				stuck.Remaining = append(stuck.Remaining, fmt.Sprintf("%s %s", l.kind, obj.GetName()))
+			}
+			return nil
+		})
+	}
+
+	return stuck
+}
+
+// finalizerList joins a namespace's finalizers
+func finalizerList(finalizers []v1.FinalizerName) string {
+	names := []string{}
+	for _, f := range finalizers {
+		names = append(names, string(f))
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,120 @@
+package smoketests
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCreateAndDeleteNamespace(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := CreateNamespace(ctx, client, testNamespace); err != nil {
+		t.Fatalf("failed to create namespace: %v", err)
+	}
+	if _, err := client.CoreV1().Namespaces().Get(ctx, testNamespace, metav1.GetOptions{}); err != nil {
+		t.Fatalf("expected namespace %s to exist: %v", testNamespace, err)
+	}
+
+	if err := DeleteNamespace(ctx, client, testNamespace); err != nil {
+		t.Fatalf("failed to delete namespace: %v", err)
+	}
+	if _, err := client.CoreV1().Namespaces().Get(ctx, testNamespace, metav1.GetOptions{}); err == nil {
+		t.Fatalf("expected namespace %s to be deleted", testNamespace)
+	}
+}
+
+func TestDeleteNamespaceStuck(t *testing.T) {
+	t.Parallel()
+
+	ns := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: testNamespace},
+		Status: v1.NamespaceStatus{
+			Phase: v1.NamespaceTerminating,
+			Conditions: []v1.NamespaceCondition{
+				{Type: v1.NamespaceDeletionContentFailure, Status: v1.ConditionFalse, Message: "All content successfully deleted"},
+				{Type: v1.NamespaceFinalizersRemaining, Status: v1.ConditionTrue, Message: "Some content in the namespace has finalizers remaining: example.com/hold in 1 resource instances"},
+			},
+		},
+	}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "held", Namespace: testNamespace, Finalizers: []string{"example.com/hold"}}}
+	claim := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: testNamespace, Finalizers: []string{"kubernetes.io/pvc-protection"}}}
+	statefulSet := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: testNamespace}}
+	client := fake.NewSimpleClientset(ns, pod, claim, statefulSet)
+	// the namespace is terminating, but never goes away
+	client.PrependReactor("delete", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	err := DeleteNamespace(ctx, client, testNamespace)
+	stuck := &NamespaceStuckError{}
+	if !errors.As(err, &stuck) {
+		t.Fatalf("expected a NamespaceStuckError, got: %v", err)
+	}
+	if len(stuck.Conditions) != 1 || !strings.HasPrefix(stuck.Conditions[0], string(v1.NamespaceFinalizersRemaining)) {
+		t.Errorf("expected the FinalizersRemaining condition only, got: %v", stuck.Conditions)
+	}
+	if want := "pod held (example.com/hold), persistentvolumeclaim data (kubernetes.io/pvc-protection)"; strings.Join(stuck.Finalizers, ", ") != want {
+		t.Errorf("expected %s, got: %v", want, stuck.Finalizers)
+	}
+	if len(stuck.Remaining) != 1 || stuck.Remaining[0] != "statefulset web" {
+		t.Errorf("expected the statefulset without finalizers, got: %v", stuck.Remaining)
+	}
+	if timeout := (&TimeoutError{}); !errors.As(err, &timeout) || !strings.Contains(timeout.Phase, "to be deleted (phase Terminating)") {
+		t.Errorf("expected a TimeoutError naming the namespace's phase, got: %v", err)
+	}
+}
+
+func TestCreateNamespaceLabels(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := CreateNamespace(withRunID(ctx, "0123456789ab"), client, testNamespace); err != nil {
+		t.Fatalf("failed to create namespace: %v", err)
+	}
+	ns, err := client.CoreV1().Namespaces().Get(ctx, testNamespace, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected namespace %s to exist: %v", testNamespace, err)
+	}
+
+	if ns.Labels[LabelManagedBy] != ManagedBy || ns.Labels[LabelRunID] != "0123456789ab" {
+		t.Errorf("expected the managed-by and run id labels, got: %v", ns.Labels)
+	}
+	created, err := strconv.ParseInt(ns.Labels[LabelCreated], 10, 64)
+	if err != nil || time.Since(time.Unix(created, 0)) > time.Minute {
+		t.Errorf("expected the created label to be the current unix time, got: %q", ns.Labels[LabelCreated])
+	}
+
+	if err := CreateNamespace(ctx, client, testNamespace); !apierrors.IsAlreadyExists(err) {
+		t.Errorf("expected an already exists error creating a namespace that already exists, got: %v", err)
+	}
+}
+
+func TestNamespaceName(t *testing.T) {
+	if name := NamespaceName("0123456789ab"); name != "kube-smoketest-0123456789ab" {
+		t.Errorf("expected kube-smoketest-0123456789ab, got: %q", name)
+	}
+}
@@ -0,0 +1,63 @@
+// Package smoketests ... the objects checks create, and removing them
+package smoketests
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jpillora/backoff"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// object is an object a check creates, see track
+type object struct {
+	kind      string
+	namespace string
+	name      string
+	// get returns nil if the object exists, and a NotFound error if it does not
+	get func(ctx context.Context) error
+	// delete deletes the object
+	delete func(ctx context.Context) error
+	// wait waits until the deleted object is gone, if nil get is polled until it returns NotFound
+	wait func(ctx context.Context) error
+}
+
+func (o object) String() string {
+	if o.namespace == "" {
+		return fmt.Sprintf("%s %s", o.kind, o.name)
+	}
+	return fmt.Sprintf("%s %s/%s", o.kind, o.namespace, o.name)
+}
+
+// remove deletes obj and waits until it's gone
+func remove(ctx context.Context, obj object) error {
+	if err := obj.delete(ctx); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete %s: %w", obj, err)
+	}
+	if obj.wait != nil {
+		return obj.wait(ctx)
+	}
+
+	bo := backoff.Backoff{
+		Min:    500 * time.Millisecond,
+		Max:    5 * time.Second,
+		Jitter: true,
+	}
+
+	for {
+		err := obj.get(ctx)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get %s: %w", obj, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s is still being deleted: %w", obj, ctx.Err())
+		case <-time.After(bo.Duration()):
+		}
+	}
+}
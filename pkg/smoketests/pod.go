@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"strconv"
 	"strings"
 
 	"github.com/golang/glog"
@@ -15,20 +14,21 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
-// CreatePod creates a pod, seems obvious :)
+// CreatePod creates a pod in namespace, seems obvious :)
 //
 // testName is mandatory;
-// testImage (default: alpine),
+// testImage (default: the configured images.pod),
 // command (default: /bin/sh),
-// args (default: while true; do echo `date`; sleep 1; done)
-func CreatePod(ctx context.Context, client *kubernetes.Clientset, testName string, testImage string, command, args []string) (*v1.Pod, error) {
+// args (default: while true; do echo `date`; sleep 1; done);
+// opts add volumes, environment variables etc.
+func CreatePod(ctx context.Context, client kubernetes.Interface, namespace string, testName string, testImage string, command, args []string, opts ...PodOption) (*v1.Pod, error) {
 
 	if testName == "" {
 		return nil, fmt.Errorf("failed to create pod: must specify a testName when creating a pod")
 	}
 
 	if testImage == "" {
-		testImage = "alpine"
+		testImage = cfg.Images.Pod
 	}
 
 	if len(command) < 1 && len(args) < 1 {
@@ -44,9 +44,9 @@ func CreatePod(ctx context.Context, client *kubernetes.Clientset, testName strin
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      strings.ToLower(testName),
 			Namespace: namespace,
-			Labels: map[string]string{
+			Labels: objectLabels(map[string]string{
 				"testName": testName,
-			},
+			}),
 		},
 		Spec: v1.PodSpec{
 			Containers: []v1.Container{
@@ -61,94 +61,155 @@ func CreatePod(ctx context.Context, client *kubernetes.Clientset, testName strin
 			RestartPolicy: v1.RestartPolicyNever,
 		},
 	}
-	opts := metav1.CreateOptions{}
+	for _, o := range opts {
+		o.applyPod(pod)
+	}
 
-	pod, err := client.CoreV1().Pods(namespace).Create(ctx, pod, opts)
+	pod, err := client.CoreV1().Pods(namespace).Create(ctx, pod, metav1.CreateOptions{})
 	if err != nil {
 		glog.V(2).Infoln(err.Error())
 		return nil, err
 	}
+	track(ctx, podObject(client, namespace, pod.Name))
 	glog.V(2).Infof("pod %s created", pod.GetName())
 
 	return pod, nil
 }
 
-// PodLogs retrievs a pod's last 10 log lines and logs them to stdout, it returns with non-nil if any error was found
-func PodLogs(ctx context.Context, client *kubernetes.Clientset) error {
+// PodOption represents a optional argument to CreatePod
+type PodOption interface {
+	applyPod(*v1.Pod)
+}
 
-	pod, err := CreatePod(ctx, client, "PodLogs", "", nil, nil)
-	if err != nil {
-		glog.Errorf("failed to create pod: %v", err)
-		return err
-	}
+// ---
+type podOptionFunc func(*v1.Pod)
 
-	if err = WaitFor(ctx, client, Pod, WithPodName(pod.Name)); err != nil {
-		glog.Errorf("failed waiting for pod to become ready: %v", err.Error())
-		return err
-	}
+func (f podOptionFunc) applyPod(pod *v1.Pod) {
+	f(pod)
+}
 
-	podList, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
-	if err != nil {
-		glog.Errorf("failed to get pod list: %v", err)
-		return err
-	}
+// WithVolume adds volume to the pod, mounted at mountPath
+func WithVolume(volume v1.Volume, mountPath string) PodOption {
+	return podOptionFunc(func(pod *v1.Pod) {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, volume)
+		c := &pod.Spec.Containers[0]
+		c.VolumeMounts = append(c.VolumeMounts, v1.VolumeMount{Name: volume.Name, MountPath: mountPath})
+	})
+}
 
-	if len(podList.Items) < 1 {
-		glog.Errorf("no pods found: %v", len(podList.Items))
-		return err
-	}
+// WithVolumeMount mounts a volume added with WithVolume once more, e.g. a single file of it with mount.SubPath
+func WithVolumeMount(mount v1.VolumeMount) PodOption {
+	return podOptionFunc(func(pod *v1.Pod) {
+		c := &pod.Spec.Containers[0]
+		c.VolumeMounts = append(c.VolumeMounts, mount)
+	})
+}
 
-	pod = &podList.DeepCopy().Items[0]
+// WithEnv sets an environment variable of the pod's container, e.g. from a key of a Secret
+func WithEnv(env v1.EnvVar) PodOption {
+	return podOptionFunc(func(pod *v1.Pod) {
+		c := &pod.Spec.Containers[0]
+		c.Env = append(c.Env, env)
+	})
+}
 
-	logLines := int64(10)
-	logOptions := &v1.PodLogOptions{
-		TypeMeta: metav1.TypeMeta{
-			APIVersion: "v1",
-			Kind:       "Pod",
-		},
-		TailLines: &logLines,
-	}
+// WithEnvFrom sets environment variables of the pod's container from all keys of a ConfigMap or Secret
+func WithEnvFrom(source v1.EnvFromSource) PodOption {
+	return podOptionFunc(func(pod *v1.Pod) {
+		c := &pod.Spec.Containers[0]
+		c.EnvFrom = append(c.EnvFrom, source)
+	})
+}
 
-	rc := client.RESTClient()
-	req := rc.Get().
-		Prefix("/api/v1/"). // TODO: find out why this is necessary to make this request work ?
-		Resource("pods").
-		Namespace(namespace).
-		Name(pod.Name).
-		SubResource("log").
-		Param("tailLines", strconv.FormatInt(*logOptions.TailLines, 10))
+// WithoutNode keeps the pod off the node called name, e.g. to test a volume can be attached to another node
+func WithoutNode(name string) PodOption {
+	return podOptionFunc(func(pod *v1.Pod) {
+		pod.Spec.Affinity = &v1.Affinity{
+			NodeAffinity: &v1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+					NodeSelectorTerms: []v1.NodeSelectorTerm{
+						{
+							MatchFields: []v1.NodeSelectorRequirement{
+								{Key: "metadata.name", Operator: v1.NodeSelectorOpNotIn, Values: []string{name}},
+							},
+						},
+					},
+				},
+			},
+		}
+	})
+}
 
-	if glog.V(10) {
-		// debug output
-		r := *req.URL()
-		glog.Infof("Request: %s://%s%s", r.Scheme, r.Host, r.Path)
+// ---
+
+// PodLogs retrievs a pod's last 10 log lines and logs them to stdout, it returns with non-nil if any error was found
+func PodLogs(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	podName := strings.ToLower("PodLogs")
+
+	if _, err := CreatePod(ctx, client, namespace, "PodLogs", "", nil, nil); err != nil {
+		glog.Errorf("failed to create pod: %v", err)
+		return err
 	}
 
-	readCloser, err := req.Stream(ctx)
-	if err != nil {
-		glog.Errorf(err.Error())
+	if err := WaitFor(ctx, client, Pod, WithNamespace(namespace), WithPodName(podName)); err != nil {
+		glog.Errorf("failed waiting for pod to become ready: %v", err.Error())
 		return err
 	}
-	defer readCloser.Close()
 
-	out := bytes.NewBuffer(nil)
-	_, err = io.Copy(out, readCloser)
+	output, err := GetPodLogs(ctx, client, namespace, podName)
 	if err != nil {
-		glog.Errorf(err.Error())
 		return err
 	}
 
 	if glog.V(2) {
-		o, _ := ioutil.ReadAll(out)
-		oo := strings.Split(string(o), "\n")
-		glog.Infoln(oo[0:])
+		glog.Infoln(output[0:])
 	}
 
 	return nil
 }
 
-// GetPodLogs gets a Pod's logs :)
-func GetPodLogs(ctx context.Context, client *kubernetes.Clientset, podName string) ([]string, error) {
+// loggedValues parses the last line of output that is not empty as space separated key=value pairs, it returns nil
+// if all lines are empty
+func loggedValues(output []string) map[string]string {
+	for i := len(output) - 1; i >= 0; i-- {
+		if strings.TrimSpace(output[i]) == "" {
+			continue
+		}
+		values := map[string]string{}
+		for _, field := range strings.Fields(output[i]) {
+			if kv := strings.SplitN(field, "=", 2); len(kv) == 2 {
+				values[kv[0]] = kv[1]
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// podObject is a pod created by CreatePod
+func podObject(client kubernetes.Interface, namespace, name string) object {
+	pods := client.CoreV1().Pods(namespace)
+	return object{
+		kind:      "pod",
+		namespace: namespace,
+		name:      name,
+		get: func(ctx context.Context) error {
+			_, err := pods.Get(ctx, name, metav1.GetOptions{})
+			return err
+		},
+		delete: func(ctx context.Context) error {
+			return pods.Delete(ctx, name, metav1.DeleteOptions{})
+		},
+	}
+}
+
+// podLogStream streams a pod's logs, tests replace it as the fake clientset cannot stream logs
+var podLogStream = func(ctx context.Context, client kubernetes.Interface, namespace, podName string, opts *v1.PodLogOptions) (io.ReadCloser, error) {
+	return client.CoreV1().Pods(namespace).GetLogs(podName, opts).Stream(ctx)
+}
+
+// GetPodLogs gets a Pod's last 10 log lines :)
+func GetPodLogs(ctx context.Context, client kubernetes.Interface, namespace, podName string) ([]string, error) {
 
 	pod, err := client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
 	if err != nil {
@@ -165,22 +226,12 @@ func GetPodLogs(ctx context.Context, client *kubernetes.Clientset, podName strin
 		TailLines: &logLines,
 	}
 
-	rc := client.RESTClient()
-	req := rc.Get().
-		Prefix("/api/v1/"). // TODO: find out why this is necessary to make this request work ?
-		Resource("pods").
-		Namespace(namespace).
-		Name(pod.Name).
-		SubResource("log").
-		Param("tailLines", strconv.FormatInt(*logOptions.TailLines, 10))
-
 	if glog.V(10) {
 		// debug output
-		r := *req.URL()
-		glog.Infof("Request: %s://%s%s", r.Scheme, r.Host, r.Path)
+		glog.Infof("Request: logs of pod %s/%s, tailLines=%d", namespace, pod.Name, *logOptions.TailLines)
 	}
 
-	readCloser, err := req.Stream(ctx)
+	readCloser, err := podLogStream(ctx, client, namespace, pod.Name, logOptions)
 	if err != nil {
 		glog.Errorf(err.Error())
 		return nil, err
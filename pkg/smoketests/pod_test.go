@@ -0,0 +1,121 @@
+package smoketests
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// stubPodLogs makes all pods log output until the test finished
+func stubPodLogs(t *testing.T, output string) {
+	orig := podLogStream
+	podLogStream = func(ctx context.Context, client kubernetes.Interface, namespace, podName string, opts *v1.PodLogOptions) (io.ReadCloser, error) {
+		client.CoreV1().Pods(namespace).GetLogs(podName, opts) // records the action, but cannot stream
+		return ioutil.NopCloser(strings.NewReader(output)), nil
+	}
+	t.Cleanup(func() { podLogStream = orig })
+}
+
+// podsStartRunning makes every pod created through client start in phase Running
+func podsStartRunning(client *fake.Clientset) {
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		pod := action.(k8stesting.CreateAction).GetObject().(*v1.Pod)
+		pod.Status.Phase = v1.PodRunning
+		return false, nil, nil
+	})
+}
+
+func TestCreatePodRequiresName(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	if _, err := CreatePod(context.Background(), client, testNamespace, "", "", nil, nil); err == nil {
+		t.Fatal("expected an error when creating a pod without testName")
+	}
+}
+
+func TestCreatePodDefaults(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	pod, err := CreatePod(context.Background(), client, testNamespace, "Defaults", "", nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create pod: %v", err)
+	}
+
+	if pod.Name != "defaults" {
+		t.Errorf("expected pod name %q, got: %q", "defaults", pod.Name)
+	}
+	container := pod.Spec.Containers[0]
+	if container.Image != "alpine" {
+		t.Errorf("expected default image %q, got: %q", "alpine", container.Image)
+	}
+	if len(container.Command) != 1 || container.Command[0] != "/bin/sh" {
+		t.Errorf("expected default command /bin/sh, got: %v", container.Command)
+	}
+}
+
+func TestPodLogs(t *testing.T) {
+	stubPodLogs(t, "Mon May  4 19:01:48 UTC 2020\nMon May  4 19:01:49 UTC 2020\n")
+
+	client := fake.NewSimpleClientset()
+	podsStartRunning(client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := PodLogs(ctx, client, testNamespace); err != nil {
+		t.Fatalf("expected pod logs to succeed, got: %v", err)
+	}
+}
+
+func TestGetPodLogs(t *testing.T) {
+	stubPodLogs(t, "first\nsecond")
+
+	client := fake.NewSimpleClientset(testPod("logs", v1.PodRunning))
+
+	lines, err := GetPodLogs(context.Background(), client, testNamespace, "logs")
+	if err != nil {
+		t.Fatalf("failed to get pod logs: %v", err)
+	}
+	if len(lines) != 2 || lines[0] != "first" || lines[1] != "second" {
+		t.Errorf("unexpected log lines: %q", lines)
+	}
+
+	if _, err := GetPodLogs(context.Background(), client, testNamespace, "missing"); err == nil {
+		t.Error("expected an error getting logs of a missing pod")
+	}
+}
+
+func TestPodLogsImagePullBackOff(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		pod := action.(k8stesting.CreateAction).GetObject().(*v1.Pod)
+		pod.Status.Phase = v1.PodPending
+		pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+			Name:  pod.Spec.Containers[0].Name,
+			State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: `Back-off pulling image "alpine"`}},
+		}}
+		return false, nil, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result := Execute(ctx, client, testNamespace, NewCheck(CheckPodLogs, "", nil, PodLogs))
+	if result.Status != StatusFail || !strings.Contains(result.Message, "ImagePullBackOff") {
+		t.Errorf("expected the check to fail with the pod's reason, got: %s: %s", result.Status, result.Message)
+	}
+	if result.Duration > 5*time.Second {
+		t.Errorf("expected the check to fail fast, took %v", result.Duration)
+	}
+}
@@ -0,0 +1,258 @@
+// Package smoketests ... creates a volume claim, confirms that volumes are provisioned, attached and reclaimed
+package smoketests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/uuid"
+	"github.com/jpillora/backoff"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ErrNoDefaultStorageClass is returned when no StorageClass is configured, and the cluster has no default StorageClass
+var ErrNoDefaultStorageClass = errors.New("no default StorageClass")
+
+// defaultClassAnnotations mark the cluster's default StorageClass
+var defaultClassAnnotations = []string{
+	"storageclass.kubernetes.io/is-default-class",
+	"storageclass.beta.kubernetes.io/is-default-class",
+}
+
+// pvcMountPath is where the pods of the PVC test mount the volume
+const pvcMountPath = "/data"
+
+// CreatePVC creates a persistent volume claim of the configured or default StorageClass, writes a nonce to its
+// volume from a pod and reads it back from another pod, then deletes the claim and verifies that its volume is
+// reclaimed according to its reclaim policy
+func CreatePVC(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	name := cfg.PVC.Name
+
+	class, err := storageClass(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	if err := createPVC(ctx, client, namespace, class.Name); err != nil {
+		return err
+	}
+
+	// such a claim stays pending until a pod uses it, waiting for it first would never end
+	waitForConsumer := class.VolumeBindingMode != nil && *class.VolumeBindingMode == storagev1.VolumeBindingWaitForFirstConsumer
+	if waitForConsumer {
+		glog.V(2).Infof("StorageClass %s binds volumes once a pod uses them, not waiting for persistentvolumeclaim %s", class.Name, name)
+	} else if err := waitBound(ctx, namespace, name); err != nil {
+		return err
+	}
+
+	volume := v1.Volume{
+		Name: "data",
+		VolumeSource: v1.VolumeSource{
+			PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: name},
+		},
+	}
+	nonce := uuid.New().String()
+	file := pvcMountPath + "/nonce"
+
+	node, _, err := runPod(ctx, client, namespace, "PVCWriter", fmt.Sprintf("echo %s > %s && sync", nonce, file), WithVolume(volume, pvcMountPath))
+	if err != nil {
+		return fmt.Errorf("failed to write to the volume: %w", err)
+	}
+	if err := waitBound(ctx, namespace, name); err != nil {
+		return err
+	}
+
+	opts := []PodOption{WithVolume(volume, pvcMountPath)}
+	if cfg.PVC.OtherNode && node != "" {
+		opts = append(opts, WithoutNode(node))
+	}
+	_, output, err := runPod(ctx, client, namespace, "PVCReader", "cat "+file, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to read from the volume: %w", err)
+	}
+	if !strings.Contains(strings.Join(output, " "), nonce) {
+		return fmt.Errorf("read %q from the volume, expected %q", strings.TrimSpace(strings.Join(output, " ")), nonce)
+	}
+
+	return TestVolumeReclaim(ctx, client, namespace, name)
+}
+
+// waitBound waits until the persistent volume claim called name is bound to a volume
+func waitBound(ctx context.Context, namespace, name string) error {
+	claims := v1.SchemeGroupVersion.WithResource("persistentvolumeclaims")
+	return WaitForObject(ctx, DynamicClient(ctx), claims, Target{Namespace: namespace, Name: name}, JSONPathEquals(".status.phase", string(v1.ClaimBound)))
+}
+
+// storageClass returns the configured StorageClass, or the cluster's default StorageClass if none is configured
+func storageClass(ctx context.Context, client kubernetes.Interface) (*storagev1.StorageClass, error) {
+	classes := client.StorageV1().StorageClasses()
+
+	if cfg.Storage.ClassName != "" {
+		class, err := classes.Get(ctx, cfg.Storage.ClassName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get StorageClass %s: %w", cfg.Storage.ClassName, err)
+		}
+		return class, nil
+	}
+
+	list, err := classes.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list StorageClasses: %w", err)
+	}
+	for i := range list.Items {
+		for _, annotation := range defaultClassAnnotations {
+			if list.Items[i].Annotations[annotation] == "true" {
+				return &list.Items[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w, configure one with storage.className", ErrNoDefaultStorageClass)
+}
+
+// createPVC creates the claim of the PVC test, of the configured size and StorageClass className
+func createPVC(ctx context.Context, client kubernetes.Interface, namespace, className string) error {
+	name := cfg.PVC.Name
+	glog.V(2).Infof("creating persistentvolumeclaim %s of StorageClass %s", name, className)
+
+	size, err := resource.ParseQuantity(cfg.Storage.Size)
+	if err != nil {
+		return fmt.Errorf("invalid storage size %q: %v", cfg.Storage.Size, err)
+	}
+
+	claim := &v1.PersistentVolumeClaim{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "PersistentVolumeClaim",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: objectLabels(map[string]string{
+				"testName": "pvc",
+			}),
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes:      []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			StorageClassName: &className,
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: size},
+			},
+		},
+	}
+
+	if _, err := client.CoreV1().PersistentVolumeClaims(namespace).Create(ctx, claim, metav1.CreateOptions{}); err != nil {
+		glog.Errorf("failed to create persistentvolumeclaim %s: %v", name, err)
+		return err
+	}
+	track(ctx, pvcObject(client, namespace, name))
+	return nil
+}
+
+// runPod runs script in a pod created with opts, waits for it to complete and deletes it; it returns the node the
+// pod ran on and its logs
+func runPod(ctx context.Context, client kubernetes.Interface, namespace, testName, script string, opts ...PodOption) (string, []string, error) {
+	obj := podObject(client, namespace, strings.ToLower(testName))
+
+	pod, err := CreatePod(ctx, client, namespace, testName, "", []string{"/bin/sh", "-c"}, []string{script}, opts...)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := WaitFor(ctx, client, Pod, WithNamespace(namespace), WithPodName(pod.Name), WithStatus(PodCompleted)); err != nil {
+		return "", nil, err
+	}
+
+	pod, err = client.CoreV1().Pods(namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", nil, err
+	}
+	output, err := GetPodLogs(ctx, client, namespace, pod.Name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return pod.Spec.NodeName, output, remove(ctx, obj)
+}
+
+// TestVolumeReclaim deletes the claim called name, and returns an error unless its volume is deleted, or released
+// when the volume's reclaim policy is Retain; a retained volume is deleted, leaving its storage asset behind, which
+// is reported as a warning
+func TestVolumeReclaim(ctx context.Context, client kubernetes.Interface, namespace, name string) error {
+	claim, err := client.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get persistentvolumeclaim %s: %w", name, err)
+	}
+	volumes := client.CoreV1().PersistentVolumes()
+	volume, err := volumes.Get(ctx, claim.Spec.VolumeName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get the persistent volume of persistentvolumeclaim %s: %w", name, err)
+	}
+	policy := volume.Spec.PersistentVolumeReclaimPolicy
+
+	glog.V(2).Infof("deleting persistentvolumeclaim %s, expecting persistent volume %s to be reclaimed with policy %s", name, volume.Name, policy)
+	if err := remove(ctx, pvcObject(client, namespace, name)); err != nil {
+		return err
+	}
+	if err := waitReclaimed(ctx, client, volume.Name, policy); err != nil {
+		return err
+	}
+
+	if policy == v1.PersistentVolumeReclaimRetain {
+		if err := volumes.Delete(ctx, volume.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete retained persistent volume %s: %w", volume.Name, err)
+		}
+		// the storage asset keeps costing money until someone deletes it
+		asset := "its storage asset"
+		if csi := volume.Spec.CSI; csi != nil {
+			asset = fmt.Sprintf("its storage asset %s of driver %s", csi.VolumeHandle, csi.Driver)
+		}
+		Warn(ctx, "deleted retained persistent volume %s, %s is left behind and must be deleted by hand", volume.Name, asset)
+	}
+	return nil
+}
+
+// waitReclaimed waits until the persistent volume called name, whose claim was deleted, was reclaimed according
+// to policy: deleted, released or made available again
+func waitReclaimed(ctx context.Context, client kubernetes.Interface, name string, policy v1.PersistentVolumeReclaimPolicy) error {
+	bo := backoff.Backoff{
+		Min:    500 * time.Millisecond,
+		Max:    5 * time.Second,
+		Jitter: true,
+	}
+
+	for {
+		volume, err := client.CoreV1().PersistentVolumes().Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			if policy == v1.PersistentVolumeReclaimDelete {
+				return nil
+			}
+			return fmt.Errorf("persistent volume %s was deleted, though its reclaim policy is %s", name, policy)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get persistent volume %s: %w", name, err)
+		}
+
+		phase := volume.Status.Phase
+		switch {
+		case phase == v1.VolumeFailed:
+			return fmt.Errorf("persistent volume %s failed to be reclaimed with policy %s: %s", name, policy, volume.Status.Message)
+		case policy == v1.PersistentVolumeReclaimRetain && phase == v1.VolumeReleased:
+			return nil
+		case policy == v1.PersistentVolumeReclaimRecycle && phase == v1.VolumeAvailable:
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("persistent volume %s was not reclaimed with policy %s, it's in phase %s: %w", name, policy, phase, ctx.Err())
+		case <-time.After(bo.Duration()):
+		}
+	}
+}
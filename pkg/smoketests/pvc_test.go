@@ -0,0 +1,240 @@
+package smoketests
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alex-leonhardt/kube-smoketest/pkg/config"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// defaultStorageClass returns the StorageClass "standard", marked as the cluster's default
+func defaultStorageClass() *storagev1.StorageClass {
+	return &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "standard",
+			Annotations: map[string]string{"storageclass.kubernetes.io/is-default-class": "true"},
+		},
+	}
+}
+
+// bindVolume binds claim to a new volume with reclaim policy policy, like a provisioner would
+func bindVolume(client *fake.Clientset, claim *v1.PersistentVolumeClaim, policy v1.PersistentVolumeReclaimPolicy) error {
+	volume := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-" + claim.Name},
+		Spec:       v1.PersistentVolumeSpec{PersistentVolumeReclaimPolicy: policy},
+		Status:     v1.PersistentVolumeStatus{Phase: v1.VolumeBound},
+	}
+	if err := client.Tracker().Add(volume); err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	claim.Spec.VolumeName = volume.Name
+	claim.Status.Phase = v1.ClaimBound
+	return nil
+}
+
+// claimsReclaimed makes client reclaim the volume of a deleted claim according to policy, like the volume
+// controller: it's deleted, or released
+func claimsReclaimed(client *fake.Clientset, policy v1.PersistentVolumeReclaimPolicy) {
+	claims := v1.SchemeGroupVersion.WithResource("persistentvolumeclaims")
+	volumes := v1.SchemeGroupVersion.WithResource("persistentvolumes")
+	client.PrependReactor("delete", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		name := action.(k8stesting.DeleteAction).GetName()
+		obj, err := client.Tracker().Get(claims, action.GetNamespace(), name)
+		if err != nil {
+			return false, nil, nil
+		}
+		if volumeName := obj.(*v1.PersistentVolumeClaim).Spec.VolumeName; volumeName != "" {
+			if policy == v1.PersistentVolumeReclaimDelete {
+				err = client.Tracker().Delete(volumes, "", volumeName)
+			} else {
+				err = client.Tracker().Update(volumes, &v1.PersistentVolume{
+					ObjectMeta: metav1.ObjectMeta{Name: volumeName},
+					Spec:       v1.PersistentVolumeSpec{PersistentVolumeReclaimPolicy: policy},
+					Status:     v1.PersistentVolumeStatus{Phase: v1.VolumeReleased},
+				}, "")
+			}
+		}
+		if apierrors.IsNotFound(err) {
+			err = nil
+		}
+		return false, nil, err
+	})
+}
+
+// volumesProvisioned adds a default StorageClass with mode and policy to client, and makes client act like the
+// volume controllers and kubelet: a claim is bound to a new volume when it's created, or with
+// WaitForFirstConsumer when a pod using it is created; pods using a claim complete; the pvcreader pod logs what
+// the pvcwriter pod wrote, the logs of other pods are not stubbed; and a deleted claim's volume is reclaimed
+// according to policy
+func volumesProvisioned(t *testing.T, client *fake.Clientset, mode storagev1.VolumeBindingMode, policy v1.PersistentVolumeReclaimPolicy) {
+	class := defaultStorageClass()
+	class.VolumeBindingMode = &mode
+	class.ReclaimPolicy = &policy
+	if err := client.Tracker().Add(class); err != nil {
+		t.Fatalf("failed to add StorageClass: %v", err)
+	}
+
+	claims := v1.SchemeGroupVersion.WithResource("persistentvolumeclaims")
+	bind := func(claim *v1.PersistentVolumeClaim) error {
+		return bindVolume(client, claim, policy)
+	}
+
+	client.PrependReactor("create", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		claim := action.(k8stesting.CreateAction).GetObject().(*v1.PersistentVolumeClaim)
+		if mode == storagev1.VolumeBindingWaitForFirstConsumer {
+			claim.Status.Phase = v1.ClaimPending
+			return false, nil, nil
+		}
+		return false, nil, bind(claim)
+	})
+	claimsReclaimed(client, policy)
+
+	mu := sync.Mutex{}
+	written := ""
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		pod := action.(k8stesting.CreateAction).GetObject().(*v1.Pod)
+		claimName := ""
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil {
+				claimName = volume.PersistentVolumeClaim.ClaimName
+			}
+		}
+		if claimName == "" {
+			return false, nil, nil
+		}
+
+		obj, err := client.Tracker().Get(claims, pod.Namespace, claimName)
+		if err != nil {
+			return true, nil, err
+		}
+		if claim := obj.(*v1.PersistentVolumeClaim); claim.Status.Phase != v1.ClaimBound {
+			if err := bind(claim); err != nil {
+				return true, nil, err
+			}
+			if err := client.Tracker().Update(claims, claim, pod.Namespace); err != nil {
+				return true, nil, err
+			}
+		}
+
+		if pod.Name == "pvcwriter" {
+			// echo <nonce> > file
+			mu.Lock()
+			written = strings.Fields(pod.Spec.Containers[0].Args[0])[1]
+			mu.Unlock()
+		}
+		pod.Spec.NodeName = "node-1"
+		pod.Status.Phase = v1.PodSucceeded
+		pod.Status.ContainerStatuses = []v1.ContainerStatus{
+			{
+				Name:  pod.Spec.Containers[0].Name,
+				State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "Completed"}},
+			},
+		}
+		return false, nil, nil
+	})
+
+	orig := podLogStream
+	podLogStream = func(ctx context.Context, client kubernetes.Interface, namespace, podName string, opts *v1.PodLogOptions) (io.ReadCloser, error) {
+		if podName != "pvcwriter" && podName != "pvcreader" {
+			return orig(ctx, client, namespace, podName, opts)
+		}
+		client.CoreV1().Pods(namespace).GetLogs(podName, opts) // records the action, but cannot stream
+		if podName == "pvcwriter" {
+			return ioutil.NopCloser(strings.NewReader("")), nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return ioutil.NopCloser(strings.NewReader(written + "\n")), nil
+	}
+	t.Cleanup(func() { podLogStream = orig })
+}
+
+func TestCreatePVC(t *testing.T) {
+	tests := []struct {
+		mode   storagev1.VolumeBindingMode
+		policy v1.PersistentVolumeReclaimPolicy
+	}{
+		{storagev1.VolumeBindingImmediate, v1.PersistentVolumeReclaimDelete},
+		{storagev1.VolumeBindingWaitForFirstConsumer, v1.PersistentVolumeReclaimDelete},
+		{storagev1.VolumeBindingImmediate, v1.PersistentVolumeReclaimRetain},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode)+"/"+string(tt.policy), func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			volumesProvisioned(t, client, tt.mode, tt.policy)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			ctx = withDynamicClient(ctx, dynamicFor(client))
+			result := &Result{}
+			ctx = withResult(ctx, result)
+
+			if err := CreatePVC(ctx, client, testNamespace); err != nil {
+				t.Fatalf("expected pvc test to succeed, got: %v", err)
+			}
+			retained := tt.policy == v1.PersistentVolumeReclaimRetain
+			if warned := len(result.Warnings) == 1 && strings.Contains(result.Warnings[0], "storage asset"); warned != retained {
+				t.Errorf("expected a warning about the storage asset left behind: %v, got: %v", retained, result.Warnings)
+			}
+
+			if _, err := client.CoreV1().PersistentVolumeClaims(testNamespace).Get(ctx, cfg.PVC.Name, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+				t.Errorf("expected the claim to be deleted, got: %v", err)
+			}
+			if _, err := client.CoreV1().PersistentVolumes().Get(ctx, "pv-"+cfg.PVC.Name, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+				t.Errorf("expected the volume to be deleted, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestCreatePVCOtherNode(t *testing.T) {
+	configure(t, func(c *config.Config) { c.PVC.OtherNode = true })
+
+	client := fake.NewSimpleClientset()
+	volumesProvisioned(t, client, storagev1.VolumeBindingImmediate, v1.PersistentVolumeReclaimDelete)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctx = withDynamicClient(ctx, dynamicFor(client))
+
+	if err := CreatePVC(ctx, client, testNamespace); err != nil {
+		t.Fatalf("expected pvc test to succeed, got: %v", err)
+	}
+
+	for _, action := range client.Actions() {
+		create, ok := action.(k8stesting.CreateAction)
+		if !ok {
+			continue
+		}
+		if pod, ok := create.GetObject().(*v1.Pod); ok && pod.Name == "pvcreader" {
+			if pod.Spec.Affinity == nil || pod.Spec.Affinity.NodeAffinity == nil {
+				t.Fatal("expected the reader to be kept off the writer's node")
+			}
+			return
+		}
+	}
+	t.Fatal("expected a reader pod to be created")
+}
+
+func TestCreatePVCNoDefaultStorageClass(t *testing.T) {
+	client := fake.NewSimpleClientset(&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "slow"}})
+
+	if err := CreatePVC(context.Background(), client, testNamespace); !errors.Is(err, ErrNoDefaultStorageClass) {
+		t.Fatalf("expected %v, got: %v", ErrNoDefaultStorageClass, err)
+	}
+}
@@ -0,0 +1,97 @@
+package smoketests
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/yaml"
+)
+
+// clusterRole reads the kube-smoketest ClusterRole from the manifests in deploy/
+func clusterRole(t *testing.T) *rbacv1.ClusterRole {
+	b, err := ioutil.ReadFile("../../deploy/rbac.yaml")
+	if err != nil {
+		t.Fatalf("failed to read rbac manifest: %v", err)
+	}
+
+	for _, doc := range bytes.Split(b, []byte("\n---\n")) {
+		role := &rbacv1.ClusterRole{}
+		if err := yaml.Unmarshal(doc, role); err != nil {
+			t.Fatalf("failed to parse rbac manifest: %v", err)
+		}
+		if role.Kind == "ClusterRole" {
+			return role
+		}
+	}
+
+	t.Fatal("no ClusterRole found in rbac manifest")
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// allows returns true if any of the role's rules allows verb on resource
+func allows(role *rbacv1.ClusterRole, verb, group, resource string) bool {
+	for _, rule := range role.Rules {
+		if contains(rule.Verbs, verb) && contains(rule.APIGroups, group) && contains(rule.Resources, resource) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestRBAC runs all checks against a fake clientset, and removes what they created, and verifies that every API
+// call they make is allowed by the ClusterRole in deploy/rbac.yaml
+func TestRBAC(t *testing.T) {
+	stubPodLogs(t, "Success\n")
+
+	client := fake.NewSimpleClientset()
+	podsStartRunning(client)
+	deploymentsBecomeAvailable(client)
+	jobsComplete(client)
+	statefulSetsRun(client, v1.PersistentVolumeReclaimRetain)
+	configMapsMounted(t, client, true)
+	secretsConsumed(t, client)
+	volumesProvisioned(t, client, storagev1.VolumeBindingImmediate, v1.PersistentVolumeReclaimRetain)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cleanup := NewCleanup()
+	dyn := dynamicFor(client)
+	ctx = WithCleanup(withDynamicClient(ctx, dyn), cleanup)
+
+	// the checks' results don't matter, only the calls they make
+	for _, check := range Checks() {
+		check.Run(ctx, client, testNamespace)
+	}
+	cleanup.Run(ctx)
+	if stale, err := FindStale(ctx, client, StaleFilter{}); err == nil {
+		RemoveStale(ctx, stale)
+	}
+
+	role := clusterRole(t)
+	for _, action := range append(client.Actions(), dyn.Actions()...) {
+		resource := action.GetResource()
+		name := resource.Resource
+		if action.GetSubresource() != "" {
+			name += "/" + action.GetSubresource()
+		}
+		if !allows(role, action.GetVerb(), resource.Group, name) {
+			t.Errorf("deploy/rbac.yaml does not allow %q on %q in API group %q", action.GetVerb(), name, resource.Group)
+		}
+	}
+}
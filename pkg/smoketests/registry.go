@@ -0,0 +1,204 @@
+// Package smoketests ... the check registry, every smoketest registers itself here and main iterates over it
+package smoketests
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// Tags used by the built-in checks
+const (
+	TagCore     = "core"
+	TagWorkload = "workload"
+	TagNetwork  = "network"
+	TagSecurity = "security"
+	TagStorage  = "storage"
+)
+
+// RunFunc is the function executing a check, it returns a non-nil error when the check failed
+type RunFunc func(ctx context.Context, client kubernetes.Interface, namespace string) error
+
+// Check is a single smoketest
+type Check interface {
+	// Name is the short, unique name of the check, e.g. "Deployment"
+	Name() string
+	// Description says what the check verifies
+	Description() string
+	// Tags are used to group checks, e.g. "network"
+	Tags() []string
+	// Dependencies are the names of the checks that must pass before this check can run
+	Dependencies() []string
+	// Run executes the check
+	Run(ctx context.Context, client kubernetes.Interface, namespace string) error
+}
+
+// check is the default Check implementation, see NewCheck
+type check struct {
+	name        string
+	description string
+	tags        []string
+	deps        []string
+	run         RunFunc
+}
+
+// NewCheck returns a Check running fn once all checks named in dependsOn passed
+func NewCheck(name, description string, tags []string, fn RunFunc, dependsOn ...string) Check {
+	return &check{
+		name:        name,
+		description: description,
+		tags:        tags,
+		deps:        dependsOn,
+		run:         fn,
+	}
+}
+
+func (c *check) Name() string           { return c.name }
+func (c *check) Description() string    { return c.description }
+func (c *check) Tags() []string         { return c.tags }
+func (c *check) Dependencies() []string { return c.deps }
+
+func (c *check) Run(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	return c.run(ctx, client, namespace)
+}
+
+// timeoutCheck is a check with a default timeout, see WithTimeout
+type timeoutCheck struct {
+	Check
+	timeout time.Duration
+}
+
+func (c *timeoutCheck) Timeout() time.Duration { return c.timeout }
+
+// WithTimeout returns c with the default timeout d, instead of timeouts.check of the config
+func WithTimeout(c Check, d time.Duration) Check {
+	return &timeoutCheck{Check: c, timeout: d}
+}
+
+// CheckTimeout returns the max. duration of check c: the timeout configured for it, its default timeout
+// (see WithTimeout), or timeouts.check of the config, in that order
+func CheckTimeout(c Check) time.Duration {
+	if d := cfg.CheckTimeout(c.Name()); d > 0 {
+		return d
+	}
+	if tc, ok := c.(interface{ Timeout() time.Duration }); ok && tc.Timeout() > 0 {
+		return tc.Timeout()
+	}
+	return cfg.Timeouts.Check.Duration
+}
+
+// HasTag returns true if the check is tagged with tag
+func HasTag(c Check, tag string) bool {
+	for _, t := range c.Tags() {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []Check
+)
+
+// Register adds a check to the registry, checks are run once their dependencies passed.
+// Register panics if a check with the same name is already registered, so it's best called from init().
+func Register(c Check) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if c == nil {
+		panic("smoketests: Register check is nil")
+	}
+	for _, r := range registry {
+		if r.Name() == c.Name() {
+			panic(fmt.Sprintf("smoketests: Register called twice for check %q", c.Name()))
+		}
+	}
+	registry = append(registry, c)
+}
+
+// Checks returns all registered checks in the order they were registered
+func Checks() []Check {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	checks := make([]Check, len(registry))
+	copy(checks, registry)
+	return checks
+}
+
+// EnabledChecks returns the registered checks that are not disabled in the configuration, in the order they
+// were registered; it fails when an enabled check depends on a disabled one, or when timeouts.run doesn't
+// leave every check its full timeout after the checks it depends on
+func EnabledChecks() ([]Check, error) {
+	enabled := []Check{}
+	for _, c := range Checks() {
+		if cfg.CheckEnabled(c.Name()) {
+			enabled = append(enabled, c)
+		}
+	}
+
+	for _, c := range enabled {
+		for _, dep := range c.Dependencies() {
+			if !cfg.CheckEnabled(dep) {
+				return nil, fmt.Errorf("check %q depends on %q, which is disabled", c.Name(), dep)
+			}
+		}
+	}
+
+	if err := ValidateRunTimeout(enabled, "timeouts.run", cfg.Timeouts.Run.Duration); err != nil {
+		return nil, err
+	}
+
+	return enabled, nil
+}
+
+// ValidateRunTimeout returns an error unless timeout, the run timeout set by setting, e.g. timeouts.run, is
+// longer than any of checks and the checks it depends on may take
+func ValidateRunTimeout(checks []Check, setting string, timeout time.Duration) error {
+	if longest, name := longestTimeout(checks); timeout <= longest {
+		return fmt.Errorf("%s must be greater than %v, check %q and the checks it depends on may take that long, got: %v",
+			setting, longest, name, timeout)
+	}
+	return nil
+}
+
+// longestTimeout returns the longest time any of checks may take, i.e. the sum of its timeout and the longest
+// time the checks it depends on may take, and the name of that check
+func longestTimeout(checks []Check) (time.Duration, string) {
+	byName := map[string]Check{}
+	for _, c := range checks {
+		byName[c.Name()] = c
+	}
+
+	totals := map[string]time.Duration{}
+	var total func(c Check) time.Duration
+	total = func(c Check) time.Duration {
+		if d, ok := totals[c.Name()]; ok {
+			return d
+		}
+		deps := time.Duration(0)
+		for _, name := range c.Dependencies() {
+			if dep, ok := byName[name]; ok {
+				if d := total(dep); d > deps {
+					deps = d
+				}
+			}
+		}
+		totals[c.Name()] = deps + CheckTimeout(c)
+		return totals[c.Name()]
+	}
+
+	longest, name := time.Duration(0), ""
+	for _, c := range checks {
+		if d := total(c); d > longest {
+			longest, name = d, c.Name()
+		}
+	}
+	return longest, name
+}
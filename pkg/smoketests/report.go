@@ -0,0 +1,118 @@
+// Package smoketests ... the report collects the results of all checks of a run
+package smoketests
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/uuid"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Cluster identifies the cluster a report was created for
+type Cluster struct {
+	// Server is the URL of the API server
+	Server string
+	// Version is the kubernetes version of the API server, e.g. v1.18.2
+	Version string
+}
+
+// DescribeCluster returns the identity of the cluster client talks to at server, the version is left
+// empty if it cannot be retrieved
+func DescribeCluster(client kubernetes.Interface, server string) Cluster {
+	cluster := Cluster{Server: server}
+
+	version, err := client.Discovery().ServerVersion()
+	if err != nil {
+		glog.Warningf("failed to get server version: %v", err)
+		return cluster
+	}
+	cluster.Version = version.GitVersion
+	return cluster
+}
+
+// NewRunID returns a new, short and unique id for a run
+func NewRunID() string {
+	id := strings.Replace(uuid.New().String(), "-", "", -1)
+	return fmt.Sprintf("%.12s", id)
+}
+
+// Report holds the results of all checks of a run, all output and the exit code are derived from it
+type Report struct {
+	RunID string
+	// Namespace is the namespace the checks created their resources in
+	Namespace string
+	Cluster   Cluster
+	Start     time.Time
+	End       time.Time
+	Results   []*Result
+
+	mu sync.Mutex
+}
+
+// NewReport returns an empty report for run runID, starting now
+func NewReport(runID string) *Report {
+	return &Report{RunID: runID, Start: time.Now()}
+}
+
+// Add appends results to the report
+func (r *Report) Add(results ...*Result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Results = append(r.Results, results...)
+	r.End = time.Now()
+}
+
+// Result returns the result of the check called name, or nil if the check is not part of the report
+func (r *Report) Result(name string) *Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, result := range r.Results {
+		if result.Name == name {
+			return result
+		}
+	}
+	return nil
+}
+
+// Count returns the number of results with status
+func (r *Report) Count(status Status) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for _, result := range r.Results {
+		if result.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Passed returns true when no check failed, errored or was skipped
+func (r *Report) Passed() bool {
+	return r.ExitCode() == 0
+}
+
+// Verdict is the overall outcome of the run, StatusFail when any check did not pass, StatusWarn when
+// all checks passed but some with warnings, StatusPass otherwise
+func (r *Report) Verdict() Status {
+	switch {
+	case !r.Passed():
+		return StatusFail
+	case r.Count(StatusWarn) > 0:
+		return StatusWarn
+	default:
+		return StatusPass
+	}
+}
+
+// ExitCode is the number of checks that did not pass, i.e. 0 when all checks passed
+func (r *Report) ExitCode() int {
+	return r.Count(StatusFail) + r.Count(StatusError) + r.Count(StatusSkip)
+}
@@ -0,0 +1,190 @@
+// Package smoketests ... the result of running a check, checks attach warnings and diagnostics through their context
+package smoketests
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Status is the outcome of a check
+type Status string
+
+// Statuses a check can end up with
+const (
+	// StatusPass means the check passed
+	StatusPass Status = "pass"
+	// StatusWarn means the check passed, but found something worth looking at, see Result.Warnings
+	StatusWarn Status = "warn"
+	// StatusFail means the check ran and found a problem with the cluster
+	StatusFail Status = "fail"
+	// StatusError means the check could not be run properly, e.g. it panicked or ran out of time before it started
+	StatusError Status = "error"
+	// StatusSkip means the check did not run, e.g. as one of its dependencies did not pass
+	StatusSkip Status = "skip"
+)
+
+// Passed returns true for statuses that count as a passed check
+func (s Status) Passed() bool {
+	return s == StatusPass || s == StatusWarn
+}
+
+// Result is the outcome of running a single check
+type Result struct {
+	Name        string
+	Description string
+	Tags        []string
+
+	Status Status
+	Start  time.Time
+	End    time.Time
+	// Duration is the time the check took to run, zero for skipped checks
+	Duration time.Duration
+	// Message describes why the check did not pass, empty if it passed
+	Message string
+	// Err is the error the check returned, or why it was skipped
+	Err error
+
+	// Warnings are problems found that do not fail the check, see Warn
+	Warnings []string
+	// Diagnostics is additional information the check collected, see Diagnose
+	Diagnostics []string
+	// Waits are the times the check waited for a resource to reach a state, see WaitFor
+	Waits []Wait
+
+	mu sync.Mutex
+}
+
+// NewResult returns a result for check c, with no status set yet
+func NewResult(c Check) *Result {
+	return &Result{
+		Name:        c.Name(),
+		Description: c.Description(),
+		Tags:        c.Tags(),
+	}
+}
+
+// SkippedResult returns the result of check c that was not run, for reason
+func SkippedResult(c Check, reason error) *Result {
+	r := NewResult(c)
+	r.skip(reason)
+	return r
+}
+
+// finish sets the result's status from the error a check returned
+func (r *Result) finish(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.End = time.Now()
+	r.Duration = r.End.Sub(r.Start)
+	r.Err = err
+
+	switch {
+	case err != nil:
+		r.Status = StatusFail
+		r.Message = err.Error()
+	case len(r.Warnings) > 0:
+		r.Status = StatusWarn
+	default:
+		r.Status = StatusPass
+	}
+}
+
+// skip marks the result as skipped for reason err
+func (r *Result) skip(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Status = StatusSkip
+	r.Err = err
+	r.Message = err.Error()
+}
+
+// fail marks the result with status, e.g. when the check could not be run at all
+func (r *Result) fail(status Status, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.End.IsZero() {
+		r.End = time.Now()
+	}
+	if !r.Start.IsZero() {
+		r.Duration = r.End.Sub(r.Start)
+	}
+	r.Status = status
+	r.Err = err
+	r.Message = err.Error()
+}
+
+// Wait is the time a check waited for a resource to reach a state
+type Wait struct {
+	// Resource is the group and resource of what was waited for, e.g. pods or deployments.apps; it's not a
+	// Resource, as WaitForObject waits for any resource
+	Resource string
+	// Phase describes what was waited for
+	Phase string
+	Start time.Time
+	// Reached is when the resource was seen in the state waited for
+	Reached time.Time
+}
+
+// Duration is how long it took the resource to reach the state
+func (w Wait) Duration() time.Duration {
+	return w.Reached.Sub(w.Start)
+}
+
+type resultKey struct{}
+
+// withResult returns a copy of ctx that checks record their warnings and diagnostics in
+func withResult(ctx context.Context, r *Result) context.Context {
+	return context.WithValue(ctx, resultKey{}, r)
+}
+
+// resultFrom returns the result stored in ctx, or nil
+func resultFrom(ctx context.Context) *Result {
+	r, _ := ctx.Value(resultKey{}).(*Result)
+	return r
+}
+
+// Warn records a warning for the check running with ctx, the check passes but is reported with StatusWarn
+func Warn(ctx context.Context, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	glog.Warningf("\t⚠️  %s", msg)
+
+	r := resultFrom(ctx)
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Warnings = append(r.Warnings, msg)
+}
+
+// Diagnose attaches diagnostic information to the result of the check running with ctx
+func Diagnose(ctx context.Context, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	glog.V(2).Infof("diagnostics: %s", msg)
+
+	r := resultFrom(ctx)
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Diagnostics = append(r.Diagnostics, msg)
+}
+
+// recordWait records a wait for the check running with ctx
+func recordWait(ctx context.Context, w Wait) {
+	r := resultFrom(ctx)
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Waits = append(r.Waits, w)
+}
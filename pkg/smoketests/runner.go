@@ -0,0 +1,240 @@
+// Package smoketests ... the runner orders checks by their dependencies and runs independent checks concurrently
+package smoketests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ErrDependencyFailed is returned for checks that were skipped as one of their dependencies did not pass
+var ErrDependencyFailed = errors.New("skipped (dependency failed)")
+
+// ErrUnknownDependency is returned when a check depends on a check that is not registered
+var ErrUnknownDependency = errors.New("unknown dependency")
+
+// ErrDependencyCycle is returned when checks depend on each other
+var ErrDependencyCycle = errors.New("dependency cycle")
+
+// Runner runs checks, honoring their dependencies
+type Runner struct {
+	// Concurrency is the max. number of checks running at the same time, defaults to 1
+	Concurrency int
+	// RunID identifies the run in the report, a new id is generated if empty
+	RunID string
+	// Namespace is the namespace the checks create their resources in, defaults to NamespaceName(RunID)
+	Namespace string
+	// Dynamic is the client checks get with DynamicClient, e.g. to wait for custom resources with WaitForObject
+	Dynamic dynamic.Interface
+}
+
+// runIDKey is the context key of the id of the run a check is part of
+type runIDKey struct{}
+
+// withRunID returns a copy of ctx carrying the id of the run
+func withRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, runID)
+}
+
+// RunID returns the id of the run the check executing with ctx is part of, or "" outside of a run
+func RunID(ctx context.Context) string {
+	id, _ := ctx.Value(runIDKey{}).(string)
+	return id
+}
+
+// graph is the dependency graph of a set of checks, by index into the list of checks
+type graph struct {
+	dependents [][]int // checks depending on check i
+	numDeps    []int   // number of dependencies of check i
+}
+
+// buildGraph builds the dependency graph for checks, it returns an error if a dependency
+// is unknown or if checks depend on each other
+func buildGraph(checks []Check) (*graph, error) {
+	index := map[string]int{}
+	for i, c := range checks {
+		index[c.Name()] = i
+	}
+
+	g := &graph{
+		dependents: make([][]int, len(checks)),
+		numDeps:    make([]int, len(checks)),
+	}
+	for i, c := range checks {
+		for _, dep := range c.Dependencies() {
+			j, ok := index[dep]
+			if !ok {
+				return nil, fmt.Errorf("%w: check %q depends on %q", ErrUnknownDependency, c.Name(), dep)
+			}
+			g.dependents[j] = append(g.dependents[j], i)
+			g.numDeps[i]++
+		}
+	}
+
+	// Kahn's algorithm, if not all checks can be visited then there must be a cycle
+	numDeps := make([]int, len(checks))
+	copy(numDeps, g.numDeps)
+	queue := []int{}
+	for i, n := range numDeps {
+		if n == 0 {
+			queue = append(queue, i)
+		}
+	}
+	visited := 0
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		visited++
+		for _, j := range g.dependents[i] {
+			numDeps[j]--
+			if numDeps[j] == 0 {
+				queue = append(queue, j)
+			}
+		}
+	}
+	if visited != len(checks) {
+		cyclic := []string{}
+		for i, n := range numDeps {
+			if n > 0 {
+				cyclic = append(cyclic, checks[i].Name())
+			}
+		}
+		return nil, fmt.Errorf("%w between checks: %s", ErrDependencyCycle, strings.Join(cyclic, ", "))
+	}
+
+	return g, nil
+}
+
+// Execute runs check c in namespace within its timeout (see CheckTimeout) and returns its result, a check
+// that panics is reported with StatusError
+func Execute(ctx context.Context, client kubernetes.Interface, namespace string, c Check) (result *Result) {
+	result = NewResult(c)
+
+	if err := ctx.Err(); err != nil {
+		result.fail(StatusError, fmt.Errorf("not started: %w", err))
+		return result
+	}
+
+	timeout := CheckTimeout(c)
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result.Start = time.Now()
+	defer func() {
+		if p := recover(); p != nil {
+			result.fail(StatusError, fmt.Errorf("check panicked: %v", p))
+		}
+	}()
+
+	err := c.Run(withResult(checkCtx, result), client, namespace)
+	if err != nil && checkCtx.Err() != nil {
+		err = timedOut(ctx, timeout, err)
+	}
+	result.finish(err)
+	return result
+}
+
+// timedOut explains err of a check whose ctx is done, unless err is a *TimeoutError explaining itself
+func timedOut(ctx context.Context, timeout time.Duration, err error) error {
+	var timeoutErr *TimeoutError
+	if errors.As(err, &timeoutErr) {
+		return err
+	}
+	if ctx.Err() != nil {
+		// the run's budget, not the check's timeout, ran out
+		return fmt.Errorf("run timed out: %w", err)
+	}
+	return fmt.Errorf("timed out after %v: %w", timeout, err)
+}
+
+// Run runs checks, each check starts as soon as all its dependencies passed, and a check is skipped
+// when any of its dependencies did not pass; results are added to the report in the order of checks
+func (r *Runner) Run(ctx context.Context, client kubernetes.Interface, checks []Check) (*Report, error) {
+	g, err := buildGraph(checks)
+	if err != nil {
+		return nil, err
+	}
+
+	concurrency := r.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	runID := r.RunID
+	if runID == "" {
+		runID = NewRunID()
+	}
+
+	namespace := r.Namespace
+	if namespace == "" {
+		namespace = NamespaceName(runID)
+	}
+	ctx = withDynamicClient(withRunID(ctx, runID), r.Dynamic)
+
+	report := NewReport(runID)
+	report.Namespace = namespace
+	results := make([]*Result, len(checks))
+	numDeps := make([]int, len(checks))
+	copy(numDeps, g.numDeps)
+	failedDeps := make([][]string, len(checks))
+
+	doneCh := make(chan int)
+	queue := []int{}
+	for i, n := range numDeps {
+		if n == 0 {
+			queue = append(queue, i)
+		}
+	}
+
+	running := 0
+	finished := 0
+
+	// finish releases or skips the dependents of check i once its result is known
+	var finish func(i int)
+	finish = func(i int) {
+		finished++
+		for _, j := range g.dependents[i] {
+			if !results[i].Status.Passed() {
+				failedDeps[j] = append(failedDeps[j], checks[i].Name())
+			}
+			numDeps[j]--
+			if numDeps[j] > 0 {
+				continue
+			}
+			if len(failedDeps[j]) > 0 {
+				glog.V(2).Infof("skipping check %q, dependencies failed: %v", checks[j].Name(), failedDeps[j])
+				results[j] = SkippedResult(checks[j], fmt.Errorf("%w: %s", ErrDependencyFailed, strings.Join(failedDeps[j], ", ")))
+				finish(j)
+				continue
+			}
+			queue = append(queue, j)
+		}
+	}
+
+	for finished < len(checks) {
+		for running < concurrency && len(queue) > 0 {
+			i := queue[0]
+			queue = queue[1:]
+			running++
+
+			go func(i int) {
+				glog.V(2).Infof("running check %q", checks[i].Name())
+				results[i] = Execute(ctx, client, namespace, checks[i])
+				doneCh <- i
+			}(i)
+		}
+
+		i := <-doneCh
+		running--
+		finish(i)
+	}
+
+	report.Add(results...)
+	return report, nil
+}
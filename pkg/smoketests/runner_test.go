@@ -0,0 +1,206 @@
+package smoketests
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alex-leonhardt/kube-smoketest/pkg/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func pass(ctx context.Context, client kubernetes.Interface, namespace string) error { return nil }
+
+func fail(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	return errors.New("failed")
+}
+
+func TestRunnerSkipsDependents(t *testing.T) {
+	checks := []Check{
+		NewCheck("setup", "", nil, fail),
+		NewCheck("uses setup", "", nil, pass, "setup"),
+		NewCheck("uses uses setup", "", nil, pass, "uses setup"),
+		NewCheck("independent", "", nil, pass),
+	}
+
+	runner := Runner{Concurrency: 2}
+	report, err := runner.Run(context.Background(), fake.NewSimpleClientset(), checks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	results := report.Results
+
+	if results[0].Status != StatusFail {
+		t.Errorf("expected %q to fail, got: %+v", checks[0].Name(), results[0])
+	}
+	for _, r := range results[1:3] {
+		if r.Status != StatusSkip || !errors.Is(r.Err, ErrDependencyFailed) {
+			t.Errorf("expected %q to be skipped, got: %+v", r.Name, r)
+		}
+	}
+	if results[3].Status != StatusPass {
+		t.Errorf("expected %q to pass, got: %v", checks[3].Name(), results[3].Err)
+	}
+	if report.ExitCode() != 3 {
+		t.Errorf("expected exit code 3, got: %d", report.ExitCode())
+	}
+}
+
+func TestRunnerWarningsAndPanics(t *testing.T) {
+	warns := func(ctx context.Context, client kubernetes.Interface, namespace string) error {
+		Warn(ctx, "not encrypted at rest")
+		Diagnose(ctx, "hexdump: 00000000")
+		return nil
+	}
+	panics := func(ctx context.Context, client kubernetes.Interface, namespace string) error {
+		var m map[string]string
+		m["boom"] = "boom"
+		return nil
+	}
+
+	checks := []Check{
+		NewCheck("warns", "", nil, warns),
+		NewCheck("panics", "", nil, panics),
+		NewCheck("after warns", "", nil, pass, "warns"),
+	}
+
+	runner := Runner{}
+	report, err := runner.Run(context.Background(), fake.NewSimpleClientset(), checks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	warned := report.Result("warns")
+	if warned.Status != StatusWarn || len(warned.Warnings) != 1 || len(warned.Diagnostics) != 1 {
+		t.Errorf("expected a warning and diagnostics, got: %+v", warned)
+	}
+	if r := report.Result("panics"); r.Status != StatusError {
+		t.Errorf("expected a panicking check to error, got: %+v", r)
+	}
+	if r := report.Result("after warns"); r.Status != StatusPass {
+		t.Errorf("expected a check depending on a warning check to run, got: %+v", r)
+	}
+	if report.Count(StatusError) != 1 || report.ExitCode() != 1 {
+		t.Errorf("expected exit code 1, got: %d", report.ExitCode())
+	}
+}
+
+func TestRunnerConcurrency(t *testing.T) {
+	var running, maxRunning int32
+	slow := func(ctx context.Context, client kubernetes.Interface, namespace string) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return nil
+	}
+
+	checks := []Check{
+		NewCheck("a", "", nil, slow),
+		NewCheck("b", "", nil, slow),
+		NewCheck("c", "", nil, slow),
+		NewCheck("d", "", nil, slow),
+	}
+
+	runner := Runner{Concurrency: 2}
+	if _, err := runner.Run(context.Background(), fake.NewSimpleClientset(), checks); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if maxRunning != 2 {
+		t.Errorf("expected 2 checks to run concurrently, got: %d", maxRunning)
+	}
+}
+
+func TestRunnerCheckTimeouts(t *testing.T) {
+	configure(t, func(c *config.Config) {
+		c.Timeouts.Check.Duration = time.Minute
+		c.Checks = map[string]config.Check{"overridden": {Timeout: metav1.Duration{Duration: 100 * time.Millisecond}}}
+	})
+
+	hangs := func(ctx context.Context, client kubernetes.Interface, namespace string) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	waits := func(ctx context.Context, client kubernetes.Interface, namespace string) error {
+		return WaitFor(ctx, client, Pod, WithNamespace(testNamespace), WithPodName("never"))
+	}
+
+	checks := []Check{
+		WithTimeout(NewCheck("hangs", "", nil, hangs), 100*time.Millisecond),
+		WithTimeout(NewCheck("overridden", "", nil, hangs), time.Hour),
+		WithTimeout(NewCheck("waits", "", nil, waits), 2*time.Second),
+		NewCheck("independent", "", nil, pass),
+	}
+	if d := CheckTimeout(checks[1]); d != 100*time.Millisecond {
+		t.Errorf("expected the configured timeout to override the check's, got: %v", d)
+	}
+	if d := CheckTimeout(checks[3]); d != time.Minute {
+		t.Errorf("expected timeouts.check for a check without a timeout, got: %v", d)
+	}
+
+	runner := Runner{Concurrency: 4}
+	report, err := runner.Run(context.Background(), fake.NewSimpleClientset(), checks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, r := range report.Results[:2] {
+		if r.Status != StatusFail || !strings.HasPrefix(r.Message, "timed out after 100ms") {
+			t.Errorf("expected %q to time out after 100ms, got: %s %q", r.Name, r.Status, r.Message)
+		}
+	}
+	waited := report.Results[2]
+	if waited.Status != StatusFail || !strings.Contains(waited.Message, `in phase "waiting for pod never to be Running`) {
+		t.Errorf("expected %q to report the phase it timed out in, got: %q", waited.Name, waited.Message)
+	}
+	if report.Results[3].Status != StatusPass {
+		t.Errorf("expected the independent check to pass, got: %v", report.Results[3].Err)
+	}
+}
+
+func TestRunnerInvalidGraph(t *testing.T) {
+	tests := []struct {
+		name   string
+		checks []Check
+		want   error
+	}{
+		{
+			name:   "unknown dependency",
+			checks: []Check{NewCheck("a", "", nil, pass, "missing")},
+			want:   ErrUnknownDependency,
+		},
+		{
+			name: "cycle",
+			checks: []Check{
+				NewCheck("a", "", nil, pass, "b"),
+				NewCheck("b", "", nil, pass, "a"),
+			},
+			want: ErrDependencyCycle,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := Runner{}
+			if _, err := runner.Run(context.Background(), fake.NewSimpleClientset(), tt.checks); !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got: %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestBuiltinChecksFormAGraph(t *testing.T) {
+	if _, err := buildGraph(Checks()); err != nil {
+		t.Fatalf("built-in checks do not form a valid dependency graph: %v", err)
+	}
+}
@@ -22,14 +22,22 @@ import (
 )
 
 // CreateSecret ... creates a secret
-func CreateSecret(ctx context.Context, client *kubernetes.Clientset) error {
+func CreateSecret(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	if err := createSecret(ctx, client, namespace, cfg.Secret.Name); err != nil {
+		return err
+	}
+
+	// verify the secret is encrypted ...
 
-	exists, err := client.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
-	if err == nil && exists != nil {
-		glog.V(2).Infof("not creating secret %s, already exists", secretName)
-		return nil
+	if err := TestSecret(ctx, client, namespace); err != nil {
+		return err
 	}
 
+	return nil
+}
+
+// createSecret creates the opaque secret called secretName, its "user" key has the value secretValue
+func createSecret(ctx context.Context, client kubernetes.Interface, namespace, secretName string) error {
 	glog.V(2).Infof("creating secret %s", secretName)
 
 	secret := &v1.Secret{
@@ -40,53 +48,96 @@ func CreateSecret(ctx context.Context, client *kubernetes.Clientset) error {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      secretName,
 			Namespace: namespace,
+			Labels:    objectLabels(nil),
 		},
 		Type: v1.SecretTypeOpaque,
 		Data: map[string][]byte{
-			"user": []byte(secretValueBase64),
+			"user": []byte(secretValue),
 		},
 	}
 
-	secret, err = client.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{})
-	if err != nil {
+	if _, err := client.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
 		return fmt.Errorf("failed to create secret: %v", err)
 	}
+	track(ctx, secretObject(client, namespace, secretName))
 
 	glog.V(2).Infof("successfully created secret %s", secretName)
+	return nil
+}
 
-	// verify the secret is encrypted ...
+// SecretPod creates a secret like CreateSecret does, mounts it as a volume and sets an environment variable from it
+// in a pod; it returns an error unless the pod reads the secret's value from both, which requires the kubelet to be
+// allowed to get the secret by the node authorizer
+func SecretPod(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	secretName := cfg.Secret.PodName
 
-	if err = TestSecret(ctx, client); err != nil {
+	if err := createSecret(ctx, client, namespace, secretName); err != nil {
 		return err
 	}
+	glog.V(2).Infof("start reading secret %s from a pod", secretName)
 
+	volume := v1.Volume{
+		Name: "secret",
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{SecretName: secretName},
+		},
+	}
+	env := v1.EnvVar{
+		Name: "SMOKETEST_USER",
+		ValueFrom: &v1.EnvVarSource{
+			SecretKeyRef: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: secretName}, Key: "user"},
+		},
+	}
+
+	_, output, err := runPod(ctx, client, namespace, "SecretPod", "echo \"env=$SMOKETEST_USER volume=$(cat /secret/user)\"",
+		WithVolume(volume, "/secret"), WithEnv(env))
+	if err != nil {
+		return err
+	}
+
+	values := loggedValues(output)
+	for _, source := range []string{"env", "volume"} {
+		if values[source] != secretValue {
+			return fmt.Errorf("pod read %q from the %s of secret %s, expected the decoded value %q", values[source], source, secretName, secretValue)
+		}
+	}
 	return nil
 }
 
+// secretObject is a secret created by CreateSecret or SecretPod
+func secretObject(client kubernetes.Interface, namespace, name string) object {
+	secrets := client.CoreV1().Secrets(namespace)
+	return object{
+		kind:      "secret",
+		namespace: namespace,
+		name:      name,
+		get: func(ctx context.Context) error {
+			_, err := secrets.Get(ctx, name, metav1.GetOptions{})
+			return err
+		},
+		delete: func(ctx context.Context) error {
+			return secrets.Delete(ctx, name, metav1.DeleteOptions{})
+		},
+	}
+}
+
 // TestSecret verifies the secret directly interrogating etcd,
 // it checks the secret's etcd content for a encryption prefix
-func TestSecret(ctx context.Context, client *kubernetes.Clientset) error {
+func TestSecret(ctx context.Context, client kubernetes.Interface, namespace string) error {
 	glog.V(2).Infoln("start verifying secret is encrypted")
-	// find ETCD hosts in cluster .. this only works in stacked deployment scenarios for now (e.g. kubeadm was used to bootstrap)
-	masterNodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{
-		LabelSelector: "node-role.kubernetes.io/master=",
-	})
+	etcdCfg := cfg.Etcd
+	secretName := cfg.Secret.Name
 
-	nodes := masterNodes.DeepCopy().Items
-	etcdEndpoints := []string{}
-	for _, n := range nodes {
-		addresses := n.Status.DeepCopy().Addresses
-		for _, addr := range addresses {
-			if addr.Type == "InternalIP" {
-				etcdEndpoints = append(etcdEndpoints, fmt.Sprintf("%s:%d", addr.Address, etcdPort))
-			}
-		}
+	etcdEndpoints, err := etcdEndpoints(ctx, client)
+	if err != nil {
+		return err
 	}
 
 	glog.V(10).Infof("list of etcd endpoints found: %v", etcdEndpoints)
-	glog.V(10).Infoln("configuring etcd client with ca=./etcd.ca cert=./etcd.crt key=./etcd.key")
+	Diagnose(ctx, "etcd endpoints: %v", etcdEndpoints)
+	glog.V(10).Infof("configuring etcd client with ca=%s cert=%s key=%s", etcdCfg.CA, etcdCfg.Cert, etcdCfg.Key)
 	// ca pool
-	cacert, err := ioutil.ReadFile("./etcd.ca")
+	cacert, err := ioutil.ReadFile(etcdCfg.CA)
 	if err != nil {
 		return fmt.Errorf("failed to read etcd CA file: %v", err)
 	}
@@ -94,9 +145,9 @@ func TestSecret(ctx context.Context, client *kubernetes.Clientset) error {
 	capool.AppendCertsFromPEM(cacert)
 
 	// client cert & key
-	certkey, err := tls.LoadX509KeyPair("./etcd.crt", "./etcd.key")
+	certkey, err := tls.LoadX509KeyPair(etcdCfg.Cert, etcdCfg.Key)
 	if err != nil {
-		return fmt.Errorf("failed to load cert/key: %v; please ensure that etcd.ca, etcd.crt and etcd.key are in the same directory the binary is run from", err)
+		return fmt.Errorf("failed to load cert/key: %v; please ensure that etcd.ca, etcd.cert and etcd.key in the config point at the etcd client certificates", err)
 	}
 
 	cli, err := clientv3.New(clientv3.Config{
@@ -105,11 +156,12 @@ func TestSecret(ctx context.Context, client *kubernetes.Clientset) error {
 			Certificates: []tls.Certificate{certkey},
 		},
 		Endpoints:   etcdEndpoints,
-		DialTimeout: time.Second,
+		Context:     ctx,
+		DialTimeout: etcdCfg.DialTimeout.Duration,
 		DialOptions: []grpc.DialOption{
-			grpc.WithTimeout(time.Second),
+			grpc.WithTimeout(etcdCfg.DialTimeout.Duration),
 		},
-		DialKeepAliveTimeout: time.Second,
+		DialKeepAliveTimeout: etcdCfg.DialTimeout.Duration,
 		LogConfig: &zap.Config{
 			Level:    zap.NewAtomicLevelAt(zapcore.ErrorLevel),
 			Encoding: "console",
@@ -119,24 +171,21 @@ func TestSecret(ctx context.Context, client *kubernetes.Clientset) error {
 		return fmt.Errorf("failed to create etcd client: %v", err)
 	}
 
-	etcdCtx, etcdCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cli.Close()
+
+	etcdCtx, etcdCancel := context.WithTimeout(ctx, 5*time.Second)
 	defer etcdCancel()
 
 	if _, err := cli.Cluster.MemberList(etcdCtx); err != nil {
-		return fmt.Errorf("failed to get etcd members using endpoint/s %v: %v", etcdEndpoints, err)
+		return fmt.Errorf("failed to get etcd members using endpoint/s %v: %w", etcdEndpoints, err)
 	}
 
-	if err != nil {
-		return fmt.Errorf("failed to create etcd client: %v", err)
-	}
-	defer cli.Close()
-
-	etcdCtx, etcdCancel = context.WithTimeout(context.Background(), 5*time.Second)
+	etcdCtx, etcdCancel = context.WithTimeout(ctx, 5*time.Second)
 	defer etcdCancel()
 
 	resp, err := cli.KV.Get(etcdCtx, "/registry/secrets/"+namespace+"/"+secretName)
 	if err != nil {
-		return fmt.Errorf("failed to get etcd key %s: %v", secretName, err)
+		return fmt.Errorf("failed to get etcd key %s: %w", secretName, err)
 	}
 
 	for _, kv := range resp.Kvs {
@@ -145,9 +194,35 @@ func TestSecret(ctx context.Context, client *kubernetes.Clientset) error {
 
 		glog.V(10).Infof("secret contents: %s", data)
 		if !strings.Contains(data, ":enc:") {
-			glog.Warningf("\t⚠️  the kubernetes secret %q is not encrypted at rest", secretName)
+			Warn(ctx, "the kubernetes secret %q is not encrypted at rest", secretName)
 		}
 	}
 
 	return nil
 }
+
+// etcdEndpoints returns the configured etcd endpoints, or the InternalIPs of the nodes matching the configured node
+// selector; the latter only works in stacked deployment scenarios (e.g. kubeadm was used to bootstrap)
+func etcdEndpoints(ctx context.Context, client kubernetes.Interface) ([]string, error) {
+	if len(cfg.Etcd.Endpoints) > 0 {
+		return cfg.Etcd.Endpoints, nil
+	}
+
+	masterNodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{
+		LabelSelector: cfg.Etcd.NodeSelector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list master nodes: %v", err)
+	}
+
+	endpoints := []string{}
+	for _, n := range masterNodes.Items {
+		for _, addr := range n.Status.Addresses {
+			if addr.Type == v1.NodeInternalIP {
+				endpoints = append(endpoints, fmt.Sprintf("%s:%d", addr.Address, cfg.Etcd.Port))
+			}
+		}
+	}
+
+	return endpoints, nil
+}
@@ -0,0 +1,89 @@
+package smoketests
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// secretsConsumed makes the secretpod pod created through client complete, and log the value of the secret's
+// user key like the kubelet would pass it to the pod, decoded; the logs of other pods are not stubbed
+func secretsConsumed(t *testing.T, client *fake.Clientset) {
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		pod := action.(k8stesting.CreateAction).GetObject().(*v1.Pod)
+		if pod.Name != "secretpod" {
+			return false, nil, nil
+		}
+		pod.Status.Phase = v1.PodSucceeded
+		pod.Status.ContainerStatuses = []v1.ContainerStatus{
+			{
+				Name:  pod.Spec.Containers[0].Name,
+				State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "Completed"}},
+			},
+		}
+		return false, nil, nil
+	})
+
+	orig := podLogStream
+	podLogStream = func(ctx context.Context, client kubernetes.Interface, namespace, podName string, opts *v1.PodLogOptions) (io.ReadCloser, error) {
+		if podName != "secretpod" {
+			return orig(ctx, client, namespace, podName, opts)
+		}
+		secret, err := client.CoreV1().Secrets(namespace).Get(ctx, cfg.Secret.PodName, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		value := string(secret.Data["user"])
+		return ioutil.NopCloser(strings.NewReader(fmt.Sprintf("env=%s volume=%s\n", value, value))), nil
+	}
+	t.Cleanup(func() { podLogStream = orig })
+}
+
+func TestSecretPod(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	secretsConsumed(t, client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := SecretPod(ctx, client, testNamespace); err != nil {
+		t.Fatalf("expected the pod to read the secret, got: %v", err)
+	}
+
+	pod, err := client.CoreV1().Pods(testNamespace).Get(ctx, "secretpod", metav1.GetOptions{})
+	if err == nil {
+		t.Errorf("expected the secretpod pod to be deleted, got: %s", pod.Name)
+	}
+}
+
+func TestSecretPodEncodedTwice(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	secretsConsumed(t, client)
+	// the value is base64 encoded before it's stored, as if created by a release that encoded it itself
+	client.PrependReactor("create", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		secret := action.(k8stesting.CreateAction).GetObject().(*v1.Secret)
+		secret.Data["user"] = []byte(base64.StdEncoding.EncodeToString(secret.Data["user"]))
+		return false, nil, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(secretValue))
+	err := SecretPod(ctx, client, testNamespace)
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("%q", encoded)) {
+		t.Fatalf("expected an error about the encoded value, got: %v", err)
+	}
+}
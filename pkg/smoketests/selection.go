@@ -0,0 +1,98 @@
+// Package smoketests ... selecting the checks to run by name and tag, along with the checks they depend on
+package smoketests
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// Selection selects checks by name and tag, the zero value selects all checks
+type Selection struct {
+	// Run selects only the checks with a matching name
+	Run *regexp.Regexp
+	// Skip deselects the checks with a matching name
+	Skip *regexp.Regexp
+	// Tags selects only the checks tagged with any of them
+	Tags []string
+}
+
+// selects returns true if c is selected by s, not taking its dependencies into account
+func (s Selection) selects(c Check) bool {
+	if s.Run != nil && !s.Run.MatchString(c.Name()) {
+		return false
+	}
+	if s.Skip != nil && s.Skip.MatchString(c.Name()) {
+		return false
+	}
+	if len(s.Tags) < 1 {
+		return true
+	}
+	for _, tag := range s.Tags {
+		if HasTag(c, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Select returns the checks selected by s and the checks they depend on, in the order of checks; it fails
+// when nothing is selected, a tag is not used by any check, or a selected check depends on a skipped one
+func Select(checks []Check, s Selection) ([]Check, error) {
+	byName := map[string]Check{}
+	for _, c := range checks {
+		byName[c.Name()] = c
+	}
+
+	for _, tag := range s.Tags {
+		used := false
+		for _, c := range checks {
+			used = used || HasTag(c, tag)
+		}
+		if !used {
+			return nil, fmt.Errorf("no check is tagged %q", tag)
+		}
+	}
+
+	selected := map[string]bool{}
+	var include func(c Check) error
+	include = func(c Check) error {
+		if selected[c.Name()] {
+			return nil
+		}
+		selected[c.Name()] = true
+		for _, name := range c.Dependencies() {
+			dep, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("check %q depends on %q, which is not enabled", c.Name(), name)
+			}
+			if s.Skip != nil && s.Skip.MatchString(name) {
+				return fmt.Errorf("check %q depends on %q, which is skipped", c.Name(), name)
+			}
+			if err := include(dep); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, c := range checks {
+		if !s.selects(c) {
+			continue
+		}
+		if err := include(c); err != nil {
+			return nil, err
+		}
+	}
+	if len(selected) < 1 {
+		return nil, errors.New("no checks selected")
+	}
+
+	result := []Check{}
+	for _, c := range checks {
+		if selected[c.Name()] {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,63 @@
+package smoketests
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func names(checks []Check) string {
+	n := []string{}
+	for _, c := range checks {
+		n = append(n, c.Name())
+	}
+	return strings.Join(n, ",")
+}
+
+func TestSelect(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		selection Selection
+		want      []string
+	}{
+		{"all", Selection{}, []string{CheckComponentStatus, CheckNamespace, CheckPodLogs, CheckDeployment, CheckService, CheckNodePortService, CheckSecret, CheckSecretPod, CheckStatefulSet, CheckPVC, CheckConfigMap}},
+		{"run pulls in dependencies", Selection{Run: regexp.MustCompile("^Service$")}, []string{CheckComponentStatus, CheckNamespace, CheckDeployment, CheckService}},
+		{"skip", Selection{Skip: regexp.MustCompile("Secret|Pod|StatefulSet|PersistentVolumeClaim|ConfigMap")}, []string{CheckComponentStatus, CheckNamespace, CheckDeployment, CheckService, CheckNodePortService}},
+		{"tags", Selection{Tags: []string{TagNetwork}}, []string{CheckComponentStatus, CheckNamespace, CheckDeployment, CheckService, CheckNodePortService}},
+		{"tags and skip", Selection{Tags: []string{TagNetwork, TagSecurity}, Skip: regexp.MustCompile("NodePort")}, []string{CheckComponentStatus, CheckNamespace, CheckDeployment, CheckService, CheckSecret, CheckSecretPod}},
+	}
+
+	for _, tt := range tests {
+		checks, err := Select(Checks(), tt.selection)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if got, want := names(checks), strings.Join(tt.want, ","); got != want {
+			t.Errorf("%s: expected %s, got: %s", tt.name, want, got)
+		}
+	}
+}
+
+func TestSelectErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		selection Selection
+		wantErr   string
+	}{
+		{"nothing selected", Selection{Run: regexp.MustCompile("^nope$")}, "no checks selected"},
+		{"unknown tag", Selection{Tags: []string{"gpu"}}, `no check is tagged "gpu"`},
+		{"dependency skipped", Selection{Run: regexp.MustCompile("^Service$"), Skip: regexp.MustCompile("^Deployment$")}, "which is skipped"},
+	}
+
+	for _, tt := range tests {
+		_, err := Select(Checks(), tt.selection)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: expected an error containing %q, got: %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
@@ -19,13 +19,8 @@ import (
 )
 
 // CreateService creates a ClusterIP service for the Deployment smoketest
-func CreateService(ctx context.Context, client *kubernetes.Clientset) error {
-	svc, err := client.CoreV1().Services(namespace).Get(ctx, serviceName, metav1.GetOptions{})
-	if err == nil && svc != nil {
-		// return early, as the service already exists, probably from an earlier run
-		glog.V(2).Infof("service %s already exists, not creating a new one", serviceName)
-		return nil
-	}
+func CreateService(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	serviceName := cfg.Service.Name
 
 	glog.V(2).Info("attempting to create service", serviceName)
 
@@ -36,10 +31,10 @@ func CreateService(ctx context.Context, client *kubernetes.Clientset) error {
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name: serviceName,
-			Labels: map[string]string{
+			Labels: objectLabels(map[string]string{
 				"app":     "smoketest",
 				"part-of": "smoketest",
-			},
+			}),
 		},
 		Spec: v1.ServiceSpec{
 			Selector: map[string]string{
@@ -60,27 +55,21 @@ func CreateService(ctx context.Context, client *kubernetes.Clientset) error {
 		},
 	}
 
-	svc, err = client.CoreV1().Services(namespace).Create(ctx, service, metav1.CreateOptions{})
+	_, err := client.CoreV1().Services(namespace).Create(ctx, service, metav1.CreateOptions{})
 	if err != nil {
 		glog.Errorf("failed to create service %s: %v", serviceName, err)
 		return err
 	}
+	track(ctx, serviceObject(client, namespace, serviceName))
 
 	glog.V(2).Infof("successfully created service %s", serviceName)
 
-	return TestService(ctx, client)
+	return TestService(ctx, client, namespace)
 }
 
 // CreateNodePortService creates a NodePort service for the Deployment smoketest
-func CreateNodePortService(ctx context.Context, client *kubernetes.Clientset) error {
-	serviceName := serviceNameNodePort
-
-	svc, err := client.CoreV1().Services(namespace).Get(ctx, serviceName, metav1.GetOptions{})
-	if err == nil && svc != nil {
-		// return early, as the service already exists, probably from an earlier run
-		glog.V(2).Infof("nodePort service %s already exists, not creating a new one", serviceName)
-		return nil
-	}
+func CreateNodePortService(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	serviceName := cfg.Service.NodePortName
 
 	glog.V(2).Info("attempting to create nodePort service", serviceName)
 
@@ -91,10 +80,10 @@ func CreateNodePortService(ctx context.Context, client *kubernetes.Clientset) er
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name: serviceName,
-			Labels: map[string]string{
+			Labels: objectLabels(map[string]string{
 				"app":     "smoketest",
 				"part-of": "smoketest",
-			},
+			}),
 		},
 		Spec: v1.ServiceSpec{
 			Selector: map[string]string{
@@ -116,64 +105,55 @@ func CreateNodePortService(ctx context.Context, client *kubernetes.Clientset) er
 		},
 	}
 
-	svc, err = client.CoreV1().Services(namespace).Create(ctx, service, metav1.CreateOptions{})
+	_, err := client.CoreV1().Services(namespace).Create(ctx, service, metav1.CreateOptions{})
 	if err != nil {
 		glog.Errorf("failed to create nodePort service %s: %v", serviceName, err)
 		return err
 	}
+	track(ctx, serviceObject(client, namespace, serviceName))
 
 	glog.V(2).Infof("successfully created nodePort service %s", serviceName)
 
-	return TestNodePortService(ctx, client)
+	return TestNodePortService(ctx, client, namespace)
+}
+
+// serviceObject is a service created by CreateService or CreateNodePortService
+func serviceObject(client kubernetes.Interface, namespace, name string) object {
+	services := client.CoreV1().Services(namespace)
+	return object{
+		kind:      "service",
+		namespace: namespace,
+		name:      name,
+		get: func(ctx context.Context) error {
+			_, err := services.Get(ctx, name, metav1.GetOptions{})
+			return err
+		},
+		delete: func(ctx context.Context) error {
+			return services.Delete(ctx, name, metav1.DeleteOptions{})
+		},
+	}
 }
 
 // DeleteService deletes the smoketest service
-func DeleteService(ctx context.Context, client *kubernetes.Clientset) error {
-	glog.Errorf("failed to delete service %s: %v", serviceName, ErrNotImplemented)
-	return ErrNotImplemented
+func DeleteService(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	if err := serviceObject(client, namespace, cfg.Service.Name).delete(ctx); err != nil {
+		glog.Errorf("failed to delete service %s: %v", cfg.Service.Name, err)
+		return err
+	}
+	return nil
 }
 
 // TestService creates a pod and curls the service endpoint, if that was not successful, then a error is returned
-func TestService(ctx context.Context, client *kubernetes.Clientset) error {
+func TestService(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	serviceName := cfg.Service.Name
 	glog.V(2).Info("start testing service", serviceName)
 
-	job, err := CreateJob(ctx, client, fmt.Sprintf("wget -o /dev/null -O /dev/null %s && echo \"Success\" || echo \"Failed\"", serviceName))
+	output, err := jobOutput(ctx, client, namespace, fmt.Sprintf("wget -o /dev/null -O /dev/null %s && echo \"Success\" || echo \"Failed\"", serviceName))
 	if err != nil {
-		glog.Errorf("failed to create svc test job: %v", err)
+		glog.Errorf("failed to run svc test job: %v", err)
 		return err
 	}
 
-	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
-		LabelSelector: fmt.Sprintf("job-name=%s", job.GetLabels()["job-name"]),
-	})
-
-	// quick loop as it may take a few seconds for Pods to be scheduled and created
-	maxTries := 3
-	for maxTries > 0 {
-		if len(pods.Items) < 1 {
-			pods, err = client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
-				LabelSelector: fmt.Sprintf("job-name=%s", job.GetLabels()["job-name"]),
-			})
-		}
-		maxTries--
-		if maxTries < 1 {
-			return err
-		}
-		time.Sleep(time.Second)
-	}
-
-	pod := pods.Items[0] // no need to guess which pod, as we should only have one that matches the label
-
-	if err = WaitFor(ctx, client, Pod, WithPodName(pod.Name), WithStatus(PodCompleted)); err != nil {
-		glog.Errorf("%v", err)
-		return err
-	}
-
-	output, err := GetPodLogs(ctx, client, pod.Name)
-	if err != nil {
-		glog.Errorf("%v", err)
-	}
-
 	if !strings.Contains(strings.Join(output, " "), "Success") {
 		return fmt.Errorf("test failed, did not find \"Success\" in output: %v", output)
 	}
@@ -183,11 +163,17 @@ func TestService(ctx context.Context, client *kubernetes.Clientset) error {
 
 // TestNodePortService calles the NodePort Service on the automatically selected port and
 // expects a 200 response, returns an error otherwise
-func TestNodePortService(ctx context.Context, client *kubernetes.Clientset) error {
-	serviceName := serviceNameNodePort
+func TestNodePortService(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	serviceName := cfg.Service.NodePortName
 	glog.V(2).Info("start testing service", serviceName)
 
 	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	if len(nodes.Items) < 1 {
+		return fmt.Errorf("no nodes found")
+	}
 
 	addresses := nodes.Items[0].Status.DeepCopy().Addresses
 	candidateIPs := []string{}
@@ -198,9 +184,16 @@ func TestNodePortService(ctx context.Context, client *kubernetes.Clientset) erro
 		}
 	}
 
+	if len(candidateIPs) < 1 {
+		return fmt.Errorf("no address found for node %s", nodes.Items[0].Name)
+	}
+
 	// -- get the nodePort service as we did not specify a port so a random
 	//    port can be picked automatically
-	svc, err := client.CoreV1().Services(namespace).Get(ctx, serviceNameNodePort, metav1.GetOptions{})
+	svc, err := client.CoreV1().Services(namespace).Get(ctx, serviceName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
 	ports := svc.Spec.DeepCopy().Ports
 
 	var nodePort int32
@@ -211,7 +204,7 @@ func TestNodePortService(ctx context.Context, client *kubernetes.Clientset) erro
 	}
 	// -- setup http connection
 	url := fmt.Sprintf("http://%s:%d", candidateIPs[0], nodePort)
-	glog.V(10).Info(serviceNameNodePort, " url: ", url)
+	glog.V(10).Info(serviceName, " url: ", url)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -0,0 +1,169 @@
+package smoketests
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// jobsComplete makes every job created through client run a pod that completed successfully
+func jobsComplete(client *fake.Clientset) {
+	client.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		job := action.(k8stesting.CreateAction).GetObject().(*batchv1.Job)
+
+		pod := testPod(job.Name+"-tester", v1.PodSucceeded)
+		pod.Labels = map[string]string{"job-name": job.Name}
+		pod.Status.ContainerStatuses = []v1.ContainerStatus{
+			{
+				Name: "box",
+				State: v1.ContainerState{
+					Terminated: &v1.ContainerStateTerminated{Reason: "Completed"},
+				},
+			},
+		}
+		if err := client.Tracker().Add(pod); err != nil {
+			return true, nil, err
+		}
+		return false, nil, nil
+	})
+}
+
+func TestTestService(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		wantErr bool
+	}{
+		{name: "success", output: "Success\n"},
+		{name: "failed", output: "Failed\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubPodLogs(t, tt.output)
+
+			client := fake.NewSimpleClientset()
+			jobsComplete(client)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			err := TestService(ctx, client, testNamespace)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestTestServiceNoPods(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := TestService(ctx, client, testNamespace); err == nil {
+		t.Fatal("expected an error when the job never created a pod")
+	}
+}
+
+func TestCreateService(t *testing.T) {
+	stubPodLogs(t, "Success\n")
+
+	client := fake.NewSimpleClientset()
+	jobsComplete(client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := CreateService(ctx, client, testNamespace); err != nil {
+		t.Fatalf("expected service test to succeed, got: %v", err)
+	}
+
+	svc, err := client.CoreV1().Services(testNamespace).Get(ctx, cfg.Service.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected service %s to be created: %v", cfg.Service.Name, err)
+	}
+	if svc.Spec.Type != v1.ServiceTypeClusterIP {
+		t.Errorf("expected service type %s, got: %s", v1.ServiceTypeClusterIP, svc.Spec.Type)
+	}
+}
+
+// nodePortClient returns a fake client with a single node and a nodePort service pointing at server
+func nodePortClient(t *testing.T, server *httptest.Server) *fake.Clientset {
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to parse test server address: %v", err)
+	}
+	nodePort, _ := strconv.Atoi(port)
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Status: v1.NodeStatus{
+			Addresses: []v1.NodeAddress{
+				{Type: v1.NodeHostName, Address: "node-1"},
+				{Type: v1.NodeInternalIP, Address: host},
+			},
+		},
+	}
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cfg.Service.NodePortName,
+			Namespace: testNamespace,
+		},
+		Spec: v1.ServiceSpec{
+			Type: v1.ServiceTypeNodePort,
+			Ports: []v1.ServicePort{
+				{Name: "http-np", Port: 80, NodePort: int32(nodePort)},
+			},
+		},
+	}
+
+	return fake.NewSimpleClientset(node, svc)
+}
+
+func TestTestNodePortService(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "ok", status: http.StatusOK},
+		{name: "unavailable", status: http.StatusServiceUnavailable, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			client := nodePortClient(t, server)
+
+			err := TestNodePortService(context.Background(), client, testNamespace)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestTestNodePortServiceNoNodes(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	if err := TestNodePortService(context.Background(), client, testNamespace); err == nil {
+		t.Fatal("expected an error when there are no nodes")
+	}
+}
@@ -0,0 +1,207 @@
+// Package smoketests ... finds and removes what earlier runs left behind, e.g. namespaces kept with -debug
+package smoketests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ErrStaleState is returned by CheckStale when earlier runs left objects behind
+var ErrStaleState = errors.New("stale state")
+
+// Stale is an object created by kube-smoketest that is still around, see FindStale
+type Stale struct {
+	object
+	// RunID is the id of the run that created the object, empty if it's not known
+	RunID string
+	// Created is when the object was created
+	Created time.Time
+}
+
+// Kind is the object's kind, e.g. namespace
+func (s Stale) Kind() string {
+	return s.kind
+}
+
+// Name is the object's name
+func (s Stale) Name() string {
+	return s.name
+}
+
+// StaleFilter selects the objects FindStale returns, the zero value selects all objects created by kube-smoketest
+type StaleFilter struct {
+	// OlderThan only selects objects created more than OlderThan ago
+	OlderThan time.Duration
+	// RunID only selects the objects created by the run with this id
+	RunID string
+	// Selector is a label selector the objects must match too, e.g. kube-smoketest/user=jane
+	Selector string
+}
+
+// FindStale returns the namespaces labeled as managed by kube-smoketest that match filter, oldest first, followed
+// by the retained persistent volumes their claims were bound to; objects in the namespaces are not returned, as
+// they are removed with them
+func FindStale(ctx context.Context, client kubernetes.Interface, filter StaleFilter) ([]Stale, error) {
+	selector, err := labels.Parse(LabelManagedBy + "=" + ManagedBy)
+	if err != nil {
+		return nil, err
+	}
+	if filter.Selector != "" {
+		extra, err := labels.Parse(filter.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %v", filter.Selector, err)
+		}
+		requirements, _ := extra.Requirements()
+		selector = selector.Add(requirements...)
+	}
+	if filter.RunID != "" {
+		runID, err := labels.Parse(LabelRunID + "=" + filter.RunID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid run id %q: %v", filter.RunID, err)
+		}
+		requirements, _ := runID.Requirements()
+		selector = selector.Add(requirements...)
+	}
+
+	namespaces, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	stale := []Stale{}
+	for _, ns := range namespaces.Items {
+		s := Stale{object: namespaceObject(client, ns.Name), RunID: ns.Labels[LabelRunID], Created: created(ns)}
+		if filter.OlderThan > 0 && time.Since(s.Created) < filter.OlderThan {
+			continue
+		}
+		stale = append(stale, s)
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Created.Before(stale[j].Created) })
+
+	// volumes come last, a volume still bound is only released once its namespace is gone
+	volumes, err := staleVolumes(ctx, client, filter, stale)
+	if err != nil {
+		return nil, err
+	}
+	return append(stale, volumes...), nil
+}
+
+// staleVolumes returns the persistent volumes with the Retain reclaim policy that were bound to a claim in one
+// of namespaces, or in a namespace of an earlier run that was already deleted; those are only matched by
+// filter's run id and age, as volumes have no labels to match the selector
+func staleVolumes(ctx context.Context, client kubernetes.Interface, filter StaleFilter, namespaces []Stale) ([]Stale, error) {
+	runIDs := map[string]string{}
+	for _, ns := range namespaces {
+		runIDs[ns.name] = ns.RunID
+	}
+
+	volumes, err := client.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list persistent volumes: %w", err)
+	}
+
+	prefix := cfg.Namespace.Prefix + "-"
+	stale := []Stale{}
+	for _, volume := range volumes.Items {
+		claim := volume.Spec.ClaimRef
+		if claim == nil || volume.Spec.PersistentVolumeReclaimPolicy != v1.PersistentVolumeReclaimRetain {
+			continue
+		}
+		s := Stale{object: volumeObject(client, volume.Name), Created: volume.CreationTimestamp.Time}
+
+		if runID, ok := runIDs[claim.Namespace]; ok {
+			s.RunID = runID
+			stale = append(stale, s)
+			continue
+		}
+
+		if volume.Status.Phase != v1.VolumeReleased || !strings.HasPrefix(claim.Namespace, prefix) || filter.Selector != "" {
+			continue
+		}
+		s.RunID = strings.TrimPrefix(claim.Namespace, prefix)
+		if filter.RunID != "" && s.RunID != filter.RunID {
+			continue
+		}
+		if filter.OlderThan > 0 && time.Since(s.Created) < filter.OlderThan {
+			continue
+		}
+		_, err := client.CoreV1().Namespaces().Get(ctx, claim.Namespace, metav1.GetOptions{})
+		if err == nil {
+			// the namespace was left out by filter
+			continue
+		}
+		if !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get namespace %s: %w", claim.Namespace, err)
+		}
+		stale = append(stale, s)
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Created.Before(stale[j].Created) })
+
+	return stale, nil
+}
+
+// volumeObject is the persistent volume called name, deleting a retained volume leaves its storage asset behind
+func volumeObject(client kubernetes.Interface, name string) object {
+	volumes := client.CoreV1().PersistentVolumes()
+	return object{
+		kind: "persistentvolume",
+		name: name,
+		get: func(ctx context.Context) error {
+			_, err := volumes.Get(ctx, name, metav1.GetOptions{})
+			return err
+		},
+		delete: func(ctx context.Context) error {
+			return volumes.Delete(ctx, name, metav1.DeleteOptions{})
+		},
+	}
+}
+
+// CheckStale returns the objects earlier runs left behind, e.g. a namespace kept with -debug and the volumes it
+// retained, and an error wrapping ErrStaleState that lists them if there are any
+func CheckStale(ctx context.Context, client kubernetes.Interface) ([]Stale, error) {
+	stale, err := FindStale(ctx, client, StaleFilter{})
+	if err != nil {
+		return nil, err
+	}
+	if len(stale) < 1 {
+		return nil, nil
+	}
+
+	objects := []string{}
+	for _, s := range stale {
+		objects = append(objects, s.String())
+	}
+	return stale, fmt.Errorf("%w, earlier runs left behind: %s", ErrStaleState, strings.Join(objects, ", "))
+}
+
+// created returns when ns was created, by the kube-smoketest/created label, or its creation timestamp
+func created(ns v1.Namespace) time.Time {
+	if unix, err := strconv.ParseInt(ns.Labels[LabelCreated], 10, 64); err == nil {
+		return time.Unix(unix, 0)
+	}
+	return ns.CreationTimestamp.Time
+}
+
+// RemoveStale removes the objects returned by FindStale and waits until they're gone, the error lists every
+// object that could not be removed
+func RemoveStale(ctx context.Context, stale []Stale) error {
+	multierr := multierror.Error{}
+	for _, s := range stale {
+		if err := remove(ctx, s.object); err != nil {
+			multierr.Errors = append(multierr.Errors, fmt.Errorf("failed to remove %s: %w", s.object, err))
+		}
+	}
+	return multierr.ErrorOrNil()
+}
@@ -0,0 +1,165 @@
+package smoketests
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// runNamespace is the namespace of the run runID, created age ago
+func runNamespace(runID string, age time.Duration) *v1.Namespace {
+	return &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: NamespaceName(runID),
+			Labels: map[string]string{
+				LabelManagedBy: ManagedBy,
+				LabelRunID:     runID,
+				LabelCreated:   strconv.FormatInt(time.Now().Add(-age).Unix(), 10),
+			},
+		},
+	}
+}
+
+func TestFindStale(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(
+		runNamespace("000000000001", time.Hour),
+		runNamespace("000000000002", 48*time.Hour),
+		runNamespace("000000000003", time.Minute),
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+	)
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		filter StaleFilter
+		want   []string
+	}{
+		{"all, oldest first", StaleFilter{}, []string{"000000000002", "000000000001", "000000000003"}},
+		{"older than", StaleFilter{OlderThan: 30 * time.Minute}, []string{"000000000002", "000000000001"}},
+		{"run id", StaleFilter{RunID: "000000000003"}, []string{"000000000003"}},
+		{"selector", StaleFilter{Selector: LabelRunID + " in (000000000001,000000000003)"}, []string{"000000000001", "000000000003"}},
+	}
+
+	for _, tt := range tests {
+		stale, err := FindStale(ctx, client, tt.filter)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		got := []string{}
+		for _, s := range stale {
+			if s.Kind() != "namespace" || s.Name() != NamespaceName(s.RunID) {
+				t.Errorf("%s: unexpected object: %v", tt.name, s)
+			}
+			got = append(got, s.RunID)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: expected %v, got: %v", tt.name, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: expected %v, got: %v", tt.name, tt.want, got)
+				break
+			}
+		}
+	}
+
+	if _, err := FindStale(ctx, client, StaleFilter{Selector: "not a selector!"}); err == nil {
+		t.Error("expected an error for an invalid selector")
+	}
+}
+
+// retainedVolume is a persistent volume with the Retain reclaim policy, bound to a claim in namespace until released
+func retainedVolume(name, namespace string, phase v1.PersistentVolumePhase) *v1.PersistentVolume {
+	return &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.Now()},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeReclaimPolicy: v1.PersistentVolumeReclaimRetain,
+			ClaimRef:                      &v1.ObjectReference{Namespace: namespace, Name: "data"},
+		},
+		Status: v1.PersistentVolumeStatus{Phase: phase},
+	}
+}
+
+func TestFindStaleVolumes(t *testing.T) {
+	t.Parallel()
+
+	deleted := retainedVolume("deleted", NamespaceName("000000000009"), v1.VolumeReleased)
+	deleted.Spec.PersistentVolumeReclaimPolicy = v1.PersistentVolumeReclaimDelete
+	client := fake.NewSimpleClientset(
+		runNamespace("000000000001", time.Hour),
+		runNamespace("000000000002", time.Minute),
+		retainedVolume("bound", NamespaceName("000000000001"), v1.VolumeBound),
+		retainedVolume("released", NamespaceName("000000000003"), v1.VolumeReleased),
+		retainedVolume("filtered", NamespaceName("000000000002"), v1.VolumeReleased),
+		retainedVolume("other", "default", v1.VolumeReleased),
+		deleted,
+	)
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		filter StaleFilter
+		want   []string
+	}{
+		{"all", StaleFilter{}, []string{"bound", "filtered", "released"}},
+		{"older than", StaleFilter{OlderThan: 30 * time.Minute}, []string{"bound"}},
+		{"run id of a deleted namespace", StaleFilter{RunID: "000000000003"}, []string{"released"}},
+		{"selector", StaleFilter{Selector: LabelRunID + "=000000000001"}, []string{"bound"}},
+	}
+
+	for _, tt := range tests {
+		stale, err := FindStale(ctx, client, tt.filter)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		got := []string{}
+		namespaces := true
+		for _, s := range stale {
+			if s.Kind() != "persistentvolume" {
+				if !namespaces {
+					t.Errorf("%s: expected namespaces before volumes, got: %v", tt.name, stale)
+				}
+				continue
+			}
+			namespaces = false
+			got = append(got, s.Name())
+		}
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: expected volumes %v, got: %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestRemoveStale(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(
+		runNamespace("000000000001", time.Hour),
+		retainedVolume("released", NamespaceName("000000000002"), v1.VolumeReleased),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stale, err := FindStale(ctx, client, StaleFilter{})
+	if err != nil || len(stale) != 2 {
+		t.Fatalf("expected a stale namespace and volume, got: %v, %v", stale, err)
+	}
+	if err := RemoveStale(ctx, stale); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stale, _ := FindStale(ctx, client, StaleFilter{}); len(stale) != 0 {
+		t.Errorf("expected nothing to be left, got: %v", stale)
+	}
+}
@@ -0,0 +1,505 @@
+// Package smoketests ... creates a statefulset, confirms that pods get a stable identity and storage, and are rolled out in order
+package smoketests
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/jpillora/backoff"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// statefulSetApp is the app label of the statefulset's pods, the headless service selects them by it
+const statefulSetApp = "smoketest-statefulset"
+
+// statefulSetVolume is the name of the statefulset's volume claim template, each pod's claim is named
+// <statefulSetVolume>-<pod name>
+const statefulSetVolume = "data"
+
+// CreateStatefulSet creates a statefulset with a headless service and the configured number of replicas, and
+// verifies that its pods become ready in order, resolve by name, keep name and volume when deleted, are scaled
+// down in reverse order, and that their volumes are reclaimed once the statefulset and its claims are deleted
+func CreateStatefulSet(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	name := cfg.StatefulSet.Name
+	numReplicas := cfg.StatefulSet.Replicas
+
+	// without a StorageClass the claims stay pending, and the statefulset would never become ready
+	class, err := storageClass(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	if err := createHeadlessService(ctx, client, namespace); err != nil {
+		return err
+	}
+	if err := createStatefulSet(ctx, client, namespace, class.Name); err != nil {
+		return err
+	}
+
+	if err := WaitFor(ctx, client, StatefulSet, WithNamespace(namespace), WithNumReady(numReplicas)); err != nil {
+		glog.Warningf("failed to create statefulset: %v", err)
+		return err
+	}
+	glog.V(2).Infof("successfully created statefulset %s", name)
+
+	pods, err := statefulSetPods(ctx, client, namespace)
+	if err != nil {
+		return err
+	}
+	if err := orderedReady(pods); err != nil {
+		return err
+	}
+
+	if err := TestStatefulSetDNS(ctx, client, namespace); err != nil {
+		return err
+	}
+	if err := TestStatefulSetPodIdentity(ctx, client, namespace, 1); err != nil {
+		return err
+	}
+	if err := TestStatefulSetScaleDown(ctx, client, namespace); err != nil {
+		return err
+	}
+	return reclaimStatefulSetVolumes(ctx, client, namespace)
+}
+
+// reclaimStatefulSetVolumes deletes the scaled down statefulset and its claims, which outlive it, and verifies
+// that their volumes are reclaimed like TestVolumeReclaim does; a retained volume is deleted, or every run would
+// leave one behind per replica
+func reclaimStatefulSetVolumes(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	if err := remove(ctx, statefulSetObject(client, namespace)); err != nil {
+		return err
+	}
+	for i := 0; i < int(cfg.StatefulSet.Replicas); i++ {
+		if err := TestVolumeReclaim(ctx, client, namespace, statefulSetClaim(statefulSetPodName(i))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createHeadlessService creates the service governing the statefulset, which gives its pods their DNS names
+func createHeadlessService(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	name := cfg.StatefulSet.Name
+	glog.V(2).Infof("creating headless service %s", name)
+
+	service := &v1.Service{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Service",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: objectLabels(map[string]string{
+				"app":     statefulSetApp,
+				"part-of": "smoketest",
+			}),
+		},
+		Spec: v1.ServiceSpec{
+			ClusterIP: v1.ClusterIPNone,
+			Selector: map[string]string{
+				"app": statefulSetApp,
+			},
+			Ports: []v1.ServicePort{
+				v1.ServicePort{
+					Name: "http",
+					Port: int32(80),
+					TargetPort: intstr.IntOrString{
+						Type:   intstr.Int,
+						IntVal: 80,
+					},
+					Protocol: v1.ProtocolTCP,
+				},
+			},
+		},
+	}
+
+	if _, err := client.CoreV1().Services(namespace).Create(ctx, service, metav1.CreateOptions{}); err != nil {
+		glog.Errorf("failed to create headless service %s: %v", name, err)
+		return err
+	}
+	track(ctx, serviceObject(client, namespace, name))
+	return nil
+}
+
+// createStatefulSet creates the statefulset, each of its pods gets a volume of the configured size and of
+// StorageClass className
+func createStatefulSet(ctx context.Context, client kubernetes.Interface, namespace, className string) error {
+	name := cfg.StatefulSet.Name
+	numReplicas := cfg.StatefulSet.Replicas
+	glog.V(2).Infof("creating statefulset %s", name)
+
+	size, err := resource.ParseQuantity(cfg.Storage.Size)
+	if err != nil {
+		return fmt.Errorf("invalid storage size %q: %v", cfg.Storage.Size, err)
+	}
+	claim := v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   statefulSetVolume,
+			Labels: objectLabels(nil),
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes:      []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			StorageClassName: &className,
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: size},
+			},
+		},
+	}
+
+	statefulSet := &appsv1.StatefulSet{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "apps/v1",
+			Kind:       "StatefulSet",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: objectLabels(map[string]string{
+				"testName": "statefulset",
+			}),
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:            &numReplicas,
+			ServiceName:         name,
+			PodManagementPolicy: appsv1.OrderedReadyPodManagement,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": statefulSetApp,
+				},
+			},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: objectLabels(map[string]string{
+						"app": statefulSetApp,
+					}),
+				},
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						v1.Container{
+							Image: cfg.Images.StatefulSet,
+							Name:  "webserver",
+							ReadinessProbe: &v1.Probe{
+								Handler: v1.Handler{
+									HTTPGet: &v1.HTTPGetAction{Path: "/", Port: intstr.FromInt(80)},
+								},
+								PeriodSeconds: 2,
+							},
+							VolumeMounts: []v1.VolumeMount{
+								{Name: statefulSetVolume, MountPath: "/data"},
+							},
+						},
+					},
+				},
+			},
+			VolumeClaimTemplates: []v1.PersistentVolumeClaim{claim},
+		},
+	}
+
+	// the claims outlive the statefulset, tracked first they're deleted once it's gone
+	for i := int32(0); i < numReplicas; i++ {
+		track(ctx, pvcObject(client, namespace, statefulSetClaim(statefulSetPodName(int(i)))))
+	}
+
+	if _, err := client.AppsV1().StatefulSets(namespace).Create(ctx, statefulSet, metav1.CreateOptions{}); err != nil {
+		glog.Errorf("failed to create statefulset %s: %v", name, err)
+		return err
+	}
+	track(ctx, statefulSetObject(client, namespace))
+	return nil
+}
+
+// statefulSetObject is the statefulset created by CreateStatefulSet
+func statefulSetObject(client kubernetes.Interface, namespace string) object {
+	statefulSets := client.AppsV1().StatefulSets(namespace)
+	name := cfg.StatefulSet.Name
+	return object{
+		kind:      "statefulset",
+		namespace: namespace,
+		name:      name,
+		get: func(ctx context.Context) error {
+			_, err := statefulSets.Get(ctx, name, metav1.GetOptions{})
+			return err
+		},
+		delete: func(ctx context.Context) error {
+			return statefulSets.Delete(ctx, name, metav1.DeleteOptions{})
+		},
+	}
+}
+
+// pvcObject is a persistent volume claim created by a test, or for a test by the statefulset controller
+func pvcObject(client kubernetes.Interface, namespace, name string) object {
+	claims := client.CoreV1().PersistentVolumeClaims(namespace)
+	return object{
+		kind:      "persistentvolumeclaim",
+		namespace: namespace,
+		name:      name,
+		get: func(ctx context.Context) error {
+			_, err := claims.Get(ctx, name, metav1.GetOptions{})
+			return err
+		},
+		delete: func(ctx context.Context) error {
+			return claims.Delete(ctx, name, metav1.DeleteOptions{})
+		},
+	}
+}
+
+// statefulSetPodName is the name of the statefulset's pod with ordinal i
+func statefulSetPodName(i int) string {
+	return fmt.Sprintf("%s-%d", cfg.StatefulSet.Name, i)
+}
+
+// statefulSetClaim is the name of the claim of the statefulset's pod called podName
+func statefulSetClaim(podName string) string {
+	return statefulSetVolume + "-" + podName
+}
+
+// statefulSetOrdinal returns the ordinal of the statefulset's pod called podName, false if it's not one of its pods
+func statefulSetOrdinal(podName string) (int, bool) {
+	prefix := cfg.StatefulSet.Name + "-"
+	if !strings.HasPrefix(podName, prefix) {
+		return 0, false
+	}
+	i, err := strconv.Atoi(strings.TrimPrefix(podName, prefix))
+	return i, err == nil && i >= 0
+}
+
+// statefulSetPods returns the statefulset's pods, ordered by ordinal
+func statefulSetPods(ctx context.Context, client kubernetes.Interface, namespace string) ([]v1.Pod, error) {
+	list, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: "app=" + statefulSetApp})
+	if err != nil {
+		return nil, err
+	}
+
+	pods := []v1.Pod{}
+	for _, pod := range list.Items {
+		if _, ok := statefulSetOrdinal(pod.Name); ok {
+			pods = append(pods, pod)
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		a, _ := statefulSetOrdinal(pods[i].Name)
+		b, _ := statefulSetOrdinal(pods[j].Name)
+		return a < b
+	})
+	return pods, nil
+}
+
+// readySince returns when pod became ready, false if it is not ready
+func readySince(pod *v1.Pod) (time.Time, bool) {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == v1.PodReady {
+			return c.LastTransitionTime.Time, c.Status == v1.ConditionTrue
+		}
+	}
+	return time.Time{}, false
+}
+
+// orderedReady returns an error unless pods, ordered by ordinal, have consecutive ordinals starting at 0 and
+// each pod was created after the one before it became ready
+func orderedReady(pods []v1.Pod) error {
+	for i := range pods {
+		if pods[i].Name != statefulSetPodName(i) {
+			return fmt.Errorf("expected pod %s, got: %s", statefulSetPodName(i), pods[i].Name)
+		}
+		if i == 0 {
+			continue
+		}
+
+		ready, ok := readySince(&pods[i-1])
+		if !ok {
+			return fmt.Errorf("pod %s is not ready, but %s exists", pods[i-1].Name, pods[i].Name)
+		}
+		// timestamps are in seconds, so a pod created in the second the one before became ready passes
+		if created := pods[i].CreationTimestamp.Time; created.Before(ready) {
+			return fmt.Errorf("pod %s was created at %s, before %s became ready at %s",
+				pods[i].Name, created.Format(time.RFC3339), pods[i-1].Name, ready.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// TestStatefulSetDNS runs a job requesting each of the statefulset's pods by its DNS name,
+// <pod>.<service>.<namespace>.svc, it returns an error if any name did not resolve or respond
+func TestStatefulSetDNS(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	hosts := []string{}
+	for i := 0; i < int(cfg.StatefulSet.Replicas); i++ {
+		hosts = append(hosts, fmt.Sprintf("%s.%s.%s.svc", statefulSetPodName(i), cfg.StatefulSet.Name, namespace))
+	}
+	glog.V(2).Infof("start testing statefulset pod names %s", strings.Join(hosts, ", "))
+
+	output, err := jobOutput(ctx, client, namespace, fmt.Sprintf(
+		"failed=''; for h in %s; do wget -q -T 5 -O /dev/null http://$h || failed=\"$failed $h\"; done; "+
+			"if [ -z \"$failed\" ]; then echo \"Success\"; else echo \"Failed:$failed\"; fi", strings.Join(hosts, " ")))
+	if err != nil {
+		glog.Errorf("failed to run statefulset dns test job: %v", err)
+		return err
+	}
+
+	if !strings.Contains(strings.Join(output, " "), "Success") {
+		return fmt.Errorf("statefulset pods did not resolve or respond by name: %s", strings.TrimSpace(strings.Join(output, " ")))
+	}
+	return nil
+}
+
+// TestStatefulSetPodIdentity deletes the statefulset's pod with ordinal i, and returns an error unless it is
+// recreated with the same name and volume claim, and becomes ready again
+func TestStatefulSetPodIdentity(ctx context.Context, client kubernetes.Interface, namespace string, i int) error {
+	name := statefulSetPodName(i)
+	pods := client.CoreV1().Pods(namespace)
+	claims := client.CoreV1().PersistentVolumeClaims(namespace)
+
+	pod, err := pods.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get pod %s: %w", name, err)
+	}
+	claim, err := claims.Get(ctx, statefulSetClaim(name), metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get the volume claim of pod %s: %w", name, err)
+	}
+
+	glog.V(2).Infof("deleting pod %s, expecting it back with volume claim %s", name, claim.Name)
+	if err := pods.Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+		return fmt.Errorf("failed to delete pod %s: %w", name, err)
+	}
+	if err := waitReplaced(ctx, client, namespace, name, pod.UID); err != nil {
+		return err
+	}
+	if err := WaitFor(ctx, client, StatefulSet, WithNamespace(namespace), WithNumReady(cfg.StatefulSet.Replicas)); err != nil {
+		return err
+	}
+
+	pod, err = pods.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get recreated pod %s: %w", name, err)
+	}
+	claimName := ""
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Name == statefulSetVolume && volume.PersistentVolumeClaim != nil {
+			claimName = volume.PersistentVolumeClaim.ClaimName
+		}
+	}
+	if claimName != claim.Name {
+		return fmt.Errorf("recreated pod %s uses volume claim %q, expected %q", name, claimName, claim.Name)
+	}
+
+	recreated, err := claims.Get(ctx, claimName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get the volume claim of recreated pod %s: %w", name, err)
+	}
+	if recreated.UID != claim.UID || recreated.Spec.VolumeName != claim.Spec.VolumeName {
+		return fmt.Errorf("recreated pod %s got a new volume, claim %s was replaced", name, claimName)
+	}
+
+	glog.V(2).Infof("pod %s came back with volume claim %s", name, claimName)
+	return nil
+}
+
+// waitReplaced waits until the pod called name is replaced by a pod with a different uid
+func waitReplaced(ctx context.Context, client kubernetes.Interface, namespace, name string, uid types.UID) error {
+	bo := backoff.Backoff{
+		Min:    500 * time.Millisecond,
+		Max:    5 * time.Second,
+		Jitter: true,
+	}
+
+	for {
+		pod, err := client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		switch {
+		case err == nil && pod.UID != uid:
+			return nil
+		case err != nil && !apierrors.IsNotFound(err):
+			return fmt.Errorf("failed to get pod %s: %w", name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("pod %s was not recreated: %w", name, ctx.Err())
+		case <-time.After(bo.Duration()):
+		}
+	}
+}
+
+// scaleStatefulSet sets the statefulset's number of replicas
+func scaleStatefulSet(ctx context.Context, client kubernetes.Interface, namespace string, replicas int32) error {
+	statefulSets := client.AppsV1().StatefulSets(namespace)
+	glog.V(2).Infof("scaling statefulset %s to %d replicas", cfg.StatefulSet.Name, replicas)
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		statefulSet, err := statefulSets.Get(ctx, cfg.StatefulSet.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		statefulSet.Spec.Replicas = &replicas
+		_, err = statefulSets.Update(ctx, statefulSet, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scale statefulset %s: %w", cfg.StatefulSet.Name, err)
+	}
+	return nil
+}
+
+// TestStatefulSetScaleDown scales the statefulset to 0 replicas, it returns an error unless its pods are
+// deleted in reverse order, highest ordinal first
+func TestStatefulSetScaleDown(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	pods, err := statefulSetPods(ctx, client, namespace)
+	if err != nil {
+		return err
+	}
+
+	w, err := client.CoreV1().Pods(namespace).Watch(ctx, metav1.ListOptions{LabelSelector: "app=" + statefulSetApp})
+	if err != nil {
+		return err
+	}
+	defer w.Stop()
+
+	if err := scaleStatefulSet(ctx, client, namespace, 0); err != nil {
+		return err
+	}
+
+	expected := []string{}
+	for i := len(pods) - 1; i >= 0; i-- {
+		expected = append(expected, pods[i].Name)
+	}
+	deleted := []string{}
+	for len(deleted) < len(expected) {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("statefulset %s was not scaled down, deleted pods: %v, expected: %v: %w", cfg.StatefulSet.Name, deleted, expected, ctx.Err())
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return fmt.Errorf("watching pods of statefulset %s ended, deleted pods: %v", cfg.StatefulSet.Name, deleted)
+			}
+			if event.Type != watch.Deleted {
+				continue
+			}
+			if pod, ok := event.Object.(*v1.Pod); ok {
+				if _, ok := statefulSetOrdinal(pod.Name); ok {
+					deleted = append(deleted, pod.Name)
+				}
+			}
+		}
+	}
+
+	for i := range expected {
+		if deleted[i] != expected[i] {
+			return fmt.Errorf("statefulset %s was scaled down in the wrong order, pods were deleted in order %v, expected: %v", cfg.StatefulSet.Name, deleted, expected)
+		}
+	}
+
+	glog.V(2).Infof("statefulset %s was scaled down in order %v", cfg.StatefulSet.Name, deleted)
+	return nil
+}
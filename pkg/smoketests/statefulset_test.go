@@ -0,0 +1,252 @@
+package smoketests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// statefulSetPod returns the ready pod with ordinal i of the statefulset, created and ready at created
+func statefulSetPod(i int, created time.Time, uid types.UID) *v1.Pod {
+	pod := testPod(statefulSetPodName(i), v1.PodRunning)
+	pod.UID = uid
+	pod.Labels = map[string]string{"app": statefulSetApp}
+	pod.CreationTimestamp = metav1.NewTime(created)
+	pod.Status.Conditions = []v1.PodCondition{
+		{Type: v1.PodReady, Status: v1.ConditionTrue, LastTransitionTime: metav1.NewTime(created)},
+	}
+	pod.Spec.Volumes = []v1.Volume{
+		{
+			Name: statefulSetVolume,
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: statefulSetClaim(pod.Name)},
+			},
+		},
+	}
+	return pod
+}
+
+// statefulSetsRun makes client act like the statefulset controller: a statefulset that is created or scaled
+// up gets its claims, bound to volumes with reclaim policy policy, and ready pods, one per second in order; a
+// deleted pod comes back with a new uid; and scaling down deletes the pods highest ordinal first
+func statefulSetsRun(client *fake.Clientset, policy v1.PersistentVolumeReclaimPolicy) {
+	pods := v1.SchemeGroupVersion.WithResource("pods")
+	uids := 0
+	newUID := func() types.UID {
+		uids++
+		return types.UID(fmt.Sprintf("uid-%d", uids))
+	}
+	add := func(obj runtime.Object) error {
+		if err := client.Tracker().Add(obj); err != nil && !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+		return nil
+	}
+
+	start := func(statefulSet *appsv1.StatefulSet) error {
+		created := time.Now().Add(-time.Minute).Truncate(time.Second)
+		n := *statefulSet.Spec.Replicas
+		for i := 0; i < int(n); i++ {
+			claim := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+				Name:      statefulSetClaim(statefulSetPodName(i)),
+				Namespace: testNamespace,
+				UID:       newUID(),
+			}}
+			if err := bindVolume(client, claim, policy); err != nil {
+				return err
+			}
+			if err := add(claim); err != nil {
+				return err
+			}
+			if err := add(statefulSetPod(i, created.Add(time.Duration(i)*time.Second), newUID())); err != nil {
+				return err
+			}
+		}
+		statefulSet.Status.Replicas = n
+		statefulSet.Status.ReadyReplicas = n
+		return nil
+	}
+
+	client.PrependReactor("create", "statefulsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return false, nil, start(action.(k8stesting.CreateAction).GetObject().(*appsv1.StatefulSet))
+	})
+	client.PrependReactor("update", "statefulsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		statefulSet := action.(k8stesting.UpdateAction).GetObject().(*appsv1.StatefulSet)
+		if *statefulSet.Spec.Replicas > 0 {
+			return false, nil, start(statefulSet)
+		}
+		for i := int(statefulSet.Status.Replicas) - 1; i >= 0; i-- {
+			if err := client.Tracker().Delete(pods, testNamespace, statefulSetPodName(i)); err != nil {
+				return true, nil, err
+			}
+		}
+		statefulSet.Status.Replicas = 0
+		statefulSet.Status.ReadyReplicas = 0
+		return false, nil, nil
+	})
+	client.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		name := action.(k8stesting.DeleteAction).GetName()
+		i, ok := statefulSetOrdinal(name)
+		if !ok {
+			return false, nil, nil
+		}
+		if err := client.Tracker().Delete(pods, testNamespace, name); err != nil {
+			return true, nil, err
+		}
+		return true, nil, client.Tracker().Add(statefulSetPod(i, time.Now(), newUID()))
+	})
+	claimsReclaimed(client, policy)
+}
+
+func TestCreateStatefulSet(t *testing.T) {
+	stubPodLogs(t, "Success\n")
+
+	client := fake.NewSimpleClientset(defaultStorageClass())
+	statefulSetsRun(client, v1.PersistentVolumeReclaimDelete)
+	jobsComplete(client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := CreateStatefulSet(ctx, client, testNamespace); err != nil {
+		t.Fatalf("expected statefulset test to succeed, got: %v", err)
+	}
+
+	svc, err := client.CoreV1().Services(testNamespace).Get(ctx, cfg.StatefulSet.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected headless service %s to be created: %v", cfg.StatefulSet.Name, err)
+	}
+	if svc.Spec.ClusterIP != v1.ClusterIPNone {
+		t.Errorf("expected a headless service, got cluster ip: %q", svc.Spec.ClusterIP)
+	}
+
+	if _, err := client.AppsV1().StatefulSets(testNamespace).Get(ctx, cfg.StatefulSet.Name, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected statefulset %s to be deleted, got: %v", cfg.StatefulSet.Name, err)
+	}
+	volumes, err := client.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil || len(volumes.Items) != 0 {
+		t.Errorf("expected the volumes of the statefulset to be reclaimed, got: %v, %v", volumes, err)
+	}
+}
+
+func TestCreateStatefulSetRetainedVolumes(t *testing.T) {
+	stubPodLogs(t, "Success\n")
+
+	client := fake.NewSimpleClientset(defaultStorageClass())
+	statefulSetsRun(client, v1.PersistentVolumeReclaimRetain)
+	jobsComplete(client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := CreateStatefulSet(ctx, client, testNamespace); err != nil {
+		t.Fatalf("expected statefulset test to succeed, got: %v", err)
+	}
+
+	// released, then deleted, rather than left behind by every run
+	volumes, err := client.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil || len(volumes.Items) != 0 {
+		t.Errorf("expected the retained volumes of the statefulset to be deleted, got: %v, %v", volumes, err)
+	}
+	claims, err := client.CoreV1().PersistentVolumeClaims(testNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil || len(claims.Items) != 0 {
+		t.Errorf("expected the claims of the statefulset to be deleted, got: %v, %v", claims, err)
+	}
+}
+
+func TestCreateStatefulSetDNSFailed(t *testing.T) {
+	stubPodLogs(t, "Failed: web-2.web.test.svc\n")
+
+	client := fake.NewSimpleClientset(defaultStorageClass())
+	statefulSetsRun(client, v1.PersistentVolumeReclaimDelete)
+	jobsComplete(client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := CreateStatefulSet(ctx, client, testNamespace)
+	if err == nil || !strings.Contains(err.Error(), "web-2.web.test.svc") {
+		t.Fatalf("expected an error naming the pod that did not resolve, got: %v", err)
+	}
+}
+
+func TestCreateStatefulSetNoDefaultStorageClass(t *testing.T) {
+	client := fake.NewSimpleClientset(&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "slow"}})
+
+	if err := CreateStatefulSet(context.Background(), client, testNamespace); !errors.Is(err, ErrNoDefaultStorageClass) {
+		t.Fatalf("expected %v, got: %v", ErrNoDefaultStorageClass, err)
+	}
+	if _, err := client.AppsV1().StatefulSets(testNamespace).Get(context.Background(), cfg.StatefulSet.Name, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected no statefulset to be created, got: %v", err)
+	}
+}
+
+func TestStatefulSetPodIdentityNewVolume(t *testing.T) {
+	stubPodLogs(t, "Success\n")
+
+	client := fake.NewSimpleClientset(defaultStorageClass())
+	statefulSetsRun(client, v1.PersistentVolumeReclaimDelete)
+	// the claim is replaced along with the pod
+	client.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		name := statefulSetClaim(action.(k8stesting.DeleteAction).GetName())
+		claims := v1.SchemeGroupVersion.WithResource("persistentvolumeclaims")
+		claim := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace, UID: "replaced"}}
+		return false, nil, client.Tracker().Update(claims, claim, testNamespace)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := createStatefulSet(ctx, client, testNamespace, "standard"); err != nil {
+		t.Fatalf("failed to create statefulset: %v", err)
+	}
+	err := TestStatefulSetPodIdentity(ctx, client, testNamespace, 1)
+	if err == nil || !strings.Contains(err.Error(), "got a new volume") {
+		t.Fatalf("expected an error about the new volume, got: %v", err)
+	}
+}
+
+func TestOrderedReady(t *testing.T) {
+	start := time.Date(2020, 5, 2, 19, 2, 39, 0, time.UTC)
+	// pod returns the pod with ordinal i, created and ready the given seconds after start
+	pod := func(i, created, ready int) v1.Pod {
+		p := statefulSetPod(i, start.Add(time.Duration(created)*time.Second), "")
+		p.Status.Conditions[0].LastTransitionTime = metav1.NewTime(start.Add(time.Duration(ready) * time.Second))
+		return *p
+	}
+
+	tests := []struct {
+		name    string
+		pods    []v1.Pod
+		wantErr string
+	}{
+		{name: "in order", pods: []v1.Pod{pod(0, 0, 2), pod(1, 2, 5), pod(2, 5, 6)}},
+		{name: "created before ready", pods: []v1.Pod{pod(0, 0, 2), pod(1, 2, 5), pod(2, 3, 6)}, wantErr: "before web-1 became ready"},
+		{name: "missing ordinal", pods: []v1.Pod{pod(0, 0, 2), pod(2, 2, 5)}, wantErr: "expected pod web-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := orderedReady(tt.pods)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -4,13 +4,24 @@ package smoketests
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang/glog"
 	"github.com/jpillora/backoff"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
 )
 
 // ErrNotImplemented is returned when the resource type provided is not being dealt with (yet)
@@ -25,6 +36,131 @@ var ErrWrongTypeForArgument = errors.New("wrong type for optional argument")
 // ErrUnknownPodStatus is returned when a pod status is not being handled yet
 var ErrUnknownPodStatus = errors.New("unknown pod status")
 
+// TimeoutError is returned by WaitFor when ctx is done before the resource reached the state waited for
+type TimeoutError struct {
+	// Resource is the resource WaitFor waited for, zero for WaitForObject
+	Resource Resource
+	// Phase describes what was being waited for, and the last state seen
+	Phase string
+	// Waited is how long WaitFor waited
+	Waited time.Duration
+	// Err is the ctx's error, context.DeadlineExceeded or context.Canceled
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	verb := "timed out"
+	if errors.Is(e.Err, context.Canceled) {
+		verb = "canceled"
+	}
+	return fmt.Sprintf("%s after %v in phase %q", verb, e.Waited.Round(time.Millisecond), e.Phase)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// NamespaceStuckError is returned by WaitFor when a namespace was not deleted in time, it says what keeps
+// the namespace from being deleted
+type NamespaceStuckError struct {
+	Namespace string
+	Timeout   *TimeoutError
+	// Conditions are the namespace's deletion conditions that are true, e.g. NamespaceFinalizersRemaining
+	Conditions []string
+	// Finalizers are the objects left in the namespace that have finalizers, and their finalizers
+	Finalizers []string
+	// Remaining are the other objects left in the namespace, e.g. the ones the namespace controller failed to delete
+	Remaining []string
+}
+
+func (e *NamespaceStuckError) Error() string {
+	msg := fmt.Sprintf("namespace %s is stuck terminating, %v", e.Namespace, e.Timeout)
+	if len(e.Conditions) > 0 {
+		msg += "; conditions: " + strings.Join(e.Conditions, ", ")
+	}
+	if len(e.Finalizers) > 0 {
+		msg += "; objects with finalizers: " + strings.Join(e.Finalizers, ", ")
+	}
+	if len(e.Remaining) > 0 {
+		msg += "; other objects left: " + strings.Join(e.Remaining, ", ")
+	}
+	return msg
+}
+
+func (e *NamespaceStuckError) Unwrap() error {
+	return e.Timeout
+}
+
+// PodFailedError is returned by WaitFor when a pod is in a state it will not recover from without help,
+// e.g. its image cannot be pulled
+type PodFailedError struct {
+	Pod string
+	// Container is the (init) container that failed, empty if the pod as a whole did, e.g. it cannot be scheduled
+	Container string
+	// Reason is the reason the pod or container is in that state, e.g. ImagePullBackOff
+	Reason  string
+	Message string
+}
+
+func (e *PodFailedError) Error() string {
+	what := "pod " + e.Pod
+	if e.Container != "" {
+		what += ", container " + e.Container + ","
+	}
+	if e.Message == "" {
+		return fmt.Sprintf("%s failed: %s", what, e.Reason)
+	}
+	return fmt.Sprintf("%s failed: %s: %s", what, e.Reason, e.Message)
+}
+
+// hopelessReasons are the reasons a container is waiting that it won't get out of without help
+var hopelessReasons = map[string]bool{
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"InvalidImageName":           true,
+	"CrashLoopBackOff":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+}
+
+// podFailed returns a *PodFailedError if pod is in a state it will not recover from without help, nil otherwise;
+// an unschedulable pod is only failed once it was unschedulable for longer than timeouts.unschedulable, which
+// gives the cluster autoscaler time to add a node
+func podFailed(pod *v1.Pod) error {
+	statuses := append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...)
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if waiting := status.State.Waiting; waiting != nil && hopelessReasons[waiting.Reason] {
+			return &PodFailedError{Pod: pod.Name, Container: status.Name, Reason: waiting.Reason, Message: waiting.Message}
+		}
+		if terminated := status.State.Terminated; terminated != nil && terminated.Reason == "Error" {
+			message := terminated.Message
+			if message == "" {
+				message = fmt.Sprintf("exit code %d", terminated.ExitCode)
+			}
+			return &PodFailedError{Pod: pod.Name, Container: status.Name, Reason: terminated.Reason, Message: message}
+		}
+	}
+
+	if pod.Status.Phase == v1.PodFailed {
+		return &PodFailedError{Pod: pod.Name, Reason: string(v1.PodFailed), Message: pod.Status.Message}
+	}
+	if message, since := podUnschedulable(pod); message != "" && time.Since(since) >= cfg.Timeouts.Unschedulable.Duration {
+		return &PodFailedError{Pod: pod.Name, Reason: v1.PodReasonUnschedulable, Message: message}
+	}
+	return nil
+}
+
+// podUnschedulable returns why the scheduler cannot place pod and since when, or an empty string if it can
+func podUnschedulable(pod *v1.Pod) (string, time.Time) {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == v1.PodScheduled && c.Status == v1.ConditionFalse && c.Reason == v1.PodReasonUnschedulable {
+			return c.Message, c.LastTransitionTime.Time
+		}
+	}
+	return "", time.Time{}
+}
+
 // PodStatus describes a Pod's status
 type PodStatus int
 
@@ -59,12 +195,57 @@ const (
 	Secret
 )
 
+func (r Resource) String() string {
+	switch r {
+	case Namespace:
+		return "namespace"
+	case Pod:
+		return "pod"
+	case Deployment:
+		return "deployment"
+	case StatefulSet:
+		return "statefulset"
+	case PVC:
+		return "persistentvolumeclaim"
+	case ConfigMap:
+		return "configmap"
+	case Secret:
+		return "secret"
+	default:
+		return ErrUnknownResourceType.Error()
+	}
+}
+
+// GroupVersionResource returns the API resource of r
+func (r Resource) GroupVersionResource() schema.GroupVersionResource {
+	switch r {
+	case Namespace:
+		return v1.SchemeGroupVersion.WithResource("namespaces")
+	case Pod:
+		return v1.SchemeGroupVersion.WithResource("pods")
+	case Deployment:
+		return appsv1.SchemeGroupVersion.WithResource("deployments")
+	case StatefulSet:
+		return appsv1.SchemeGroupVersion.WithResource("statefulsets")
+	case PVC:
+		return v1.SchemeGroupVersion.WithResource("persistentvolumeclaims")
+	case ConfigMap:
+		return v1.SchemeGroupVersion.WithResource("configmaps")
+	case Secret:
+		return v1.SchemeGroupVersion.WithResource("secrets")
+	default:
+		return schema.GroupVersionResource{}
+	}
+}
+
 // --- optinoal arguments to WaitFor
 
 type options struct {
-	NumReady int32
-	PodName  string
-	Status   PodStatus
+	Namespace string
+	NumReady  int32
+	PodName   string
+	Status    PodStatus
+	Deleted   bool
 }
 
 // Option represents a optional argument to WaitFor
@@ -72,6 +253,18 @@ type Option interface {
 	apply(*options)
 }
 
+// ---
+type namespaceOption string
+
+func (s namespaceOption) apply(opts *options) {
+	opts.Namespace = string(s)
+}
+
+// WithNamespace sets the Namespace to wait for, or to wait in for all other resources
+func WithNamespace(n string) Option {
+	return namespaceOption(n)
+}
+
 // ---
 type podNameOption string
 
@@ -109,10 +302,226 @@ func WithStatus(n PodStatus) Option {
 }
 
 // ---
+type deletedOption bool
+
+func (s deletedOption) apply(opts *options) {
+	opts.Deleted = bool(s)
+}
+
+// WithDeleted makes WaitFor wait until the resource is gone, only a Namespace can be waited for that way (yet)
+func WithDeleted() Option {
+	return deletedOption(true)
+}
+
+// ---
+
+// condition is what WaitFor waits for
+type condition struct {
+	// phase describes what is waited for
+	phase string
+	// namespace and name of the object waited for, namespace is empty for a namespace
+	namespace, name string
+	// objType is an empty object of the type waited for
+	objType runtime.Object
+	// lw lists and watches the object waited for
+	lw *cache.ListWatch
+	// get returns the object waited for
+	get func(ctx context.Context) (runtime.Object, error)
+	// reached returns true once obj, nil if it does not exist, is in the state waited for; and describes the
+	// state seen, e.g. "phase Pending"
+	reached func(obj runtime.Object) (bool, string)
+	// failed returns an error if obj will never reach the state waited for, nil if not set
+	failed func(obj runtime.Object) error
+}
+
+// check returns true once obj reached the state waited for, and sets state to the state seen; it returns
+// an error if obj will never reach it
+func (c *condition) check(obj runtime.Object, state *string) (bool, error) {
+	ok, s := c.reached(obj)
+	*state = s
+	if ok || obj == nil || c.failed == nil {
+		return ok, nil
+	}
+	return false, c.failed(obj)
+}
+
+// newCondition returns what WaitFor waits for, given its arguments
+func newCondition(ctx context.Context, client kubernetes.Interface, resource Resource, options options) (*condition, error) {
+	namespace := options.Namespace
+
+	switch resource {
+	case Namespace:
+		namespaces := client.CoreV1().Namespaces()
+		c := &condition{
+			name:    namespace,
+			objType: &v1.Namespace{},
+			lw: &cache.ListWatch{
+				ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+					opts.FieldSelector = nameSelector(namespace)
+					return namespaces.List(ctx, opts)
+				},
+				WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+					opts.FieldSelector = nameSelector(namespace)
+					return namespaces.Watch(ctx, opts)
+				},
+			},
+			get: func(ctx context.Context) (runtime.Object, error) {
+				return namespaces.Get(ctx, namespace, metav1.GetOptions{})
+			},
+		}
+		if options.Deleted {
+			c.phase = fmt.Sprintf("waiting for namespace %s to be deleted", namespace)
+			c.reached = func(obj runtime.Object) (bool, string) {
+				if obj == nil {
+					return true, ""
+				}
+				return false, fmt.Sprintf("phase %s", obj.(*v1.Namespace).Status.Phase)
+			}
+		} else {
+			c.phase = fmt.Sprintf("waiting for namespace %s to exist", namespace)
+			c.reached = func(obj runtime.Object) (bool, string) {
+				return obj != nil, ""
+			}
+		}
+		return c, nil
+
+	case Deployment:
+		name := cfg.Deployment.Name
+		deployments := client.AppsV1().Deployments(namespace)
+		return &condition{
+			phase:     fmt.Sprintf("waiting for deployment %s to have %d available replicas", name, options.NumReady),
+			namespace: namespace,
+			name:      name,
+			objType:   &appsv1.Deployment{},
+			lw: &cache.ListWatch{
+				ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+					opts.FieldSelector = nameSelector(name)
+					return deployments.List(ctx, opts)
+				},
+				WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+					opts.FieldSelector = nameSelector(name)
+					return deployments.Watch(ctx, opts)
+				},
+			},
+			get: func(ctx context.Context) (runtime.Object, error) {
+				return deployments.Get(ctx, name, metav1.GetOptions{})
+			},
+			reached: func(obj runtime.Object) (bool, string) {
+				if obj == nil {
+					return false, "not found"
+				}
+				available := obj.(*appsv1.Deployment).Status.AvailableReplicas
+				return available == options.NumReady, fmt.Sprintf("%d available", available)
+			},
+		}, nil
+
+	case Pod:
+		if options.Status == 0 {
+			options.Status = PodRunning // default to waiting for a Running pod
+		}
 
-// WaitFor waits for a resource to be in a ready, unready, etc. state and
-// returns with an error when the ctx timed out, or with nil
-func WaitFor(ctx context.Context, client *kubernetes.Clientset, resource Resource, opts ...Option) error {
+		name := options.PodName
+		pods := client.CoreV1().Pods(namespace)
+		return &condition{
+			phase:     fmt.Sprintf("waiting for pod %s to be %s", name, options.Status.String()),
+			namespace: namespace,
+			name:      name,
+			objType:   &v1.Pod{},
+			lw: &cache.ListWatch{
+				ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+					opts.FieldSelector = nameSelector(name)
+					return pods.List(ctx, opts)
+				},
+				WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+					opts.FieldSelector = nameSelector(name)
+					return pods.Watch(ctx, opts)
+				},
+			},
+			get: func(ctx context.Context) (runtime.Object, error) {
+				return pods.Get(ctx, name, metav1.GetOptions{})
+			},
+			reached: func(obj runtime.Object) (bool, string) {
+				if obj == nil {
+					return false, "not found"
+				}
+				pod := obj.(*v1.Pod)
+				state := fmt.Sprintf("phase %s", pod.Status.Phase)
+				if message, _ := podUnschedulable(pod); message != "" {
+					state += fmt.Sprintf(", %s: %s", v1.PodReasonUnschedulable, message)
+				}
+
+				switch options.Status {
+				case PodRunning:
+					return pod.Status.Phase == v1.PodRunning, state
+				case PodCompleted:
+					if len(pod.Status.ContainerStatuses) > 0 {
+						if terminated := pod.Status.ContainerStatuses[0].State.Terminated; terminated != nil {
+							return terminated.Reason == PodCompleted.String(), state
+						}
+					}
+				}
+				return false, state
+			},
+			failed: func(obj runtime.Object) error {
+				return podFailed(obj.(*v1.Pod))
+			},
+		}, nil
+
+	case StatefulSet:
+		name := cfg.StatefulSet.Name
+		statefulSets := client.AppsV1().StatefulSets(namespace)
+		return &condition{
+			phase:     fmt.Sprintf("waiting for statefulset %s to have %d ready replicas", name, options.NumReady),
+			namespace: namespace,
+			name:      name,
+			objType:   &appsv1.StatefulSet{},
+			lw: &cache.ListWatch{
+				ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+					opts.FieldSelector = nameSelector(name)
+					return statefulSets.List(ctx, opts)
+				},
+				WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+					opts.FieldSelector = nameSelector(name)
+					return statefulSets.Watch(ctx, opts)
+				},
+			},
+			get: func(ctx context.Context) (runtime.Object, error) {
+				return statefulSets.Get(ctx, name, metav1.GetOptions{})
+			},
+			reached: func(obj runtime.Object) (bool, string) {
+				if obj == nil {
+					return false, "not found"
+				}
+				sts := obj.(*appsv1.StatefulSet)
+				// replicas also counts pods still terminating, e.g. after scaling down
+				return sts.Status.ObservedGeneration >= sts.Generation && sts.Status.Replicas == options.NumReady && sts.Status.ReadyReplicas == options.NumReady,
+					fmt.Sprintf("%d of %d ready", sts.Status.ReadyReplicas, sts.Status.Replicas)
+			},
+		}, nil
+
+	case PVC:
+		return nil, ErrNotImplemented
+	case ConfigMap:
+		return nil, ErrNotImplemented
+	case Secret:
+		return nil, ErrNotImplemented
+	default:
+		return nil, ErrUnknownResourceType
+	}
+}
+
+// nameSelector is a field selector selecting the object named name
+func nameSelector(name string) string {
+	return fields.OneTermEqualSelector("metadata.name", name).String()
+}
+
+// WaitFor waits for a resource to be in a ready, unready, etc. state and returns nil,
+// or a *TimeoutError describing the phase it was waiting in when the ctx is done; a namespace that is not
+// deleted in time returns a *NamespaceStuckError, and a pod that will never reach the state a *PodFailedError
+// right away. The resource is watched, so the state is checked on every change; if it cannot be watched it's
+// polled instead. The time the state was reached is recorded in the result of the check running with ctx,
+// see Result.Waits.
+func WaitFor(ctx context.Context, client kubernetes.Interface, resource Resource, opts ...Option) error {
 
 	options := options{}
 
@@ -120,83 +529,125 @@ func WaitFor(ctx context.Context, client *kubernetes.Clientset, resource Resourc
 		o.apply(&options)
 	}
 
-	bo := backoff.Backoff{
-		Min:    time.Second,
-		Max:    5 * time.Second,
-		Jitter: true,
-		// Factor: float64(0.3),
+	if options.Deleted && resource != Namespace {
+		return ErrNotImplemented
+	}
+
+	c, err := newCondition(ctx, client, resource, options)
+	if err != nil {
+		return err
 	}
 
 	t := time.Now()
+	state := ""
 
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(bo.Duration()):
-			// continue below
-		}
+	err = watchFor(ctx, c, &state)
+	failed := &PodFailedError{}
+	if err != nil && ctx.Err() == nil && !errors.As(err, &failed) {
+		glog.V(2).Infof("failed to watch %s %s, polling instead: %v", resource, c.name, err)
+		err = pollFor(ctx, c, &state)
+	}
+	if err == nil {
+		reached := time.Now()
+		recordWait(ctx, Wait{Resource: resource.GroupVersionResource().GroupResource().String(), Phase: c.phase, Start: t, Reached: reached})
+		glog.V(2).Infof("%s took %v", strings.TrimPrefix(c.phase, "waiting for "), reached.Sub(t))
+		return nil
+	}
+	if ctx.Err() == nil || errors.As(err, &failed) {
+		return err
+	}
 
-		switch resource {
-		case Namespace:
-			ns, err := client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
-			if err != nil {
-				continue
-			}
-			if ns != nil {
-				return nil
-			}
-		case Deployment:
-			deployment, err := client.AppsV1().Deployments(namespace).Get(ctx, "smoketest", metav1.GetOptions{})
-			if err != nil {
-				continue
-			}
-			if deployment != nil {
-				if deployment.Status.AvailableReplicas == options.NumReady {
-					return nil
-				}
-			}
-			glog.V(2).Infof("waiting for pods to become available: %v", time.Since(t))
+	phase := c.phase
+	if state != "" {
+		phase += fmt.Sprintf(" (%s)", state)
+	}
+	timeout := &TimeoutError{Resource: resource, Phase: phase, Waited: time.Since(t), Err: ctx.Err()}
+	if resource == Namespace && options.Deleted {
+		return namespaceStuck(client, options.Namespace, timeout)
+	}
+	return timeout
+}
 
-		case Pod:
+// watchFor watches the object of c until it reached the state waited for, state is set to the state seen
+func watchFor(ctx context.Context, c *condition, state *string) error {
+	// fail early, rather than waiting for the cache to sync until ctx is done, e.g. when not allowed to list
+	if _, err := c.lw.List(metav1.ListOptions{Limit: 1}); err != nil {
+		return err
+	}
 
-			if options.Status == 0 {
-				options.Status = PodRunning // default to waiting for a Running pod
-			}
+	key := c.name
+	if c.namespace != "" {
+		key = c.namespace + "/" + c.name
+	}
 
-			tmpPod, err := client.CoreV1().Pods(namespace).Get(ctx, options.PodName, metav1.GetOptions{})
-			if err != nil {
-				continue
-			}
+	reached := func(obj runtime.Object) (bool, error) {
+		return c.check(obj, state)
+	}
 
-			switch options.Status {
-			case PodRunning:
-				if tmpPod.Status.Phase == v1.PodRunning {
-					return nil
-				}
-			case PodCompleted:
-				if len(tmpPod.Status.ContainerStatuses) > 0 {
-					if tmpPod.Status.ContainerStatuses[0].State.Terminated != nil {
-						if tmpPod.Status.ContainerStatuses[0].State.Terminated.Reason == PodCompleted.String() {
-							return nil
-						}
-					}
-				}
+	precondition := func(store cache.Store) (bool, error) {
+		obj, exists, err := store.GetByKey(key)
+		if err != nil {
+			return false, err
+		}
+		if !exists {
+			return reached(nil)
+		}
+		return reached(obj.(runtime.Object))
+	}
+
+	_, err := watchtools.UntilWithSync(ctx, c.lw, c.objType, precondition, func(event watch.Event) (bool, error) {
+		switch event.Type {
+		case watch.Error:
+			return false, apierrors.FromObject(event.Object)
+		case watch.Deleted:
+			if meta, err := apimeta.Accessor(event.Object); err == nil && meta.GetName() == c.name {
+				return reached(nil)
 			}
+			return false, nil
+		}
 
-			glog.V(2).Infof("waiting for pod to be %s: %v", options.Status.String(), time.Since(t))
+		if meta, err := apimeta.Accessor(event.Object); err != nil || meta.GetName() != c.name {
+			return false, nil
+		}
+		return reached(event.Object)
+	})
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
 
-		case StatefulSet:
-			return ErrNotImplemented
-		case PVC:
-			return ErrNotImplemented
-		case ConfigMap:
-			return ErrNotImplemented
-		case Secret:
-			return ErrNotImplemented
+// pollFor gets the object of c until it reached the state waited for, state is set to the state seen
+func pollFor(ctx context.Context, c *condition, state *string) error {
+	bo := backoff.Backoff{
+		Min:    time.Second,
+		Max:    5 * time.Second,
+		Jitter: true,
+	}
+
+	for {
+		obj, err := c.get(ctx)
+		switch {
+		case apierrors.IsNotFound(err):
+			if ok, _ := c.check(nil, state); ok {
+				return nil
+			}
+		case err != nil:
+			if ctx.Err() == nil {
+				*state = err.Error()
+			}
 		default:
-			return ErrUnknownResourceType
+			ok, err := c.check(obj, state)
+			if ok || err != nil {
+				return err
+			}
 		}
+		glog.V(2).Infof("%s (%s)", c.phase, *state)
 
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(bo.Duration()):
+		}
 	}
 }
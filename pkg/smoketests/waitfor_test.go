@@ -0,0 +1,317 @@
+package smoketests
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alex-leonhardt/kube-smoketest/pkg/config"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// testNamespace is the namespace the tests create their resources in
+const testNamespace = "kube-smoketest-test"
+
+func testPod(name string, phase v1.PodPhase) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+		},
+		Status: v1.PodStatus{
+			Phase: phase,
+		},
+	}
+}
+
+func TestWaitForNamespace(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testNamespace}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := WaitFor(ctx, client, Namespace, WithNamespace(testNamespace)); err != nil {
+		t.Fatalf("expected namespace to be found, got: %v", err)
+	}
+}
+
+func TestWaitForPodRunning(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(testPod("waitfor", v1.PodPending))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		pod := testPod("waitfor", v1.PodRunning)
+		if _, err := client.CoreV1().Pods(testNamespace).UpdateStatus(ctx, pod, metav1.UpdateOptions{}); err != nil {
+			t.Errorf("failed to update pod: %v", err)
+		}
+	}()
+
+	if err := WaitFor(ctx, client, Pod, WithNamespace(testNamespace), WithPodName("waitfor")); err != nil {
+		t.Fatalf("expected pod to become running, got: %v", err)
+	}
+}
+
+func TestWaitForPodCompleted(t *testing.T) {
+	t.Parallel()
+
+	pod := testPod("completed", v1.PodSucceeded)
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{
+		{
+			Name: "box",
+			State: v1.ContainerState{
+				Terminated: &v1.ContainerStateTerminated{Reason: "Completed"},
+			},
+		},
+	}
+	client := fake.NewSimpleClientset(pod)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := WaitFor(ctx, client, Pod, WithNamespace(testNamespace), WithPodName("completed"), WithStatus(PodCompleted)); err != nil {
+		t.Fatalf("expected pod to be completed, got: %v", err)
+	}
+}
+
+func TestWaitForPodTimeout(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(testPod("pending", v1.PodPending))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	err := WaitFor(ctx, client, Pod, WithNamespace(testNamespace), WithPodName("pending"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v, got: %v", context.DeadlineExceeded, err)
+	}
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Phase != "waiting for pod pending to be Running (phase Pending)" {
+		t.Errorf("expected the phase waited in, got: %v", err)
+	}
+}
+
+func TestWaitForPodUnschedulable(t *testing.T) {
+	configure(t, func(c *config.Config) { c.Timeouts.Unschedulable = metav1.Duration{Duration: time.Minute} })
+
+	unschedulable := func(since time.Time) *v1.Pod {
+		pod := testPod("unschedulable", v1.PodPending)
+		pod.Status.Conditions = []v1.PodCondition{{
+			Type:               v1.PodScheduled,
+			Status:             v1.ConditionFalse,
+			Reason:             v1.PodReasonUnschedulable,
+			Message:            "0/3 nodes are available",
+			LastTransitionTime: metav1.NewTime(since),
+		}}
+		return pod
+	}
+
+	// the cluster autoscaler adds a node within timeouts.unschedulable, the pod is scheduled and runs
+	client := fake.NewSimpleClientset(unschedulable(time.Now()))
+	go func() {
+		time.Sleep(time.Second)
+		client.CoreV1().Pods(testNamespace).Update(context.Background(), testPod("unschedulable", v1.PodRunning), metav1.UpdateOptions{})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := WaitFor(ctx, client, Pod, WithNamespace(testNamespace), WithPodName("unschedulable")); err != nil {
+		t.Fatalf("expected the pod to run once scheduled, got: %v", err)
+	}
+
+	// no node is added in time, the timeout says why the pod is pending
+	client = fake.NewSimpleClientset(unschedulable(time.Now()))
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	err := WaitFor(ctx, client, Pod, WithNamespace(testNamespace), WithPodName("unschedulable"))
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) || !strings.Contains(timeoutErr.Phase, "Unschedulable: 0/3 nodes are available") {
+		t.Errorf("expected a timeout naming why the pod is unschedulable, got: %v", err)
+	}
+
+	// unschedulable for longer than timeouts.unschedulable, the wait fails right away
+	client = fake.NewSimpleClientset(unschedulable(time.Now().Add(-2 * time.Minute)))
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	failed := &PodFailedError{}
+	if err := WaitFor(ctx, client, Pod, WithNamespace(testNamespace), WithPodName("unschedulable")); !errors.As(err, &failed) || failed.Reason != v1.PodReasonUnschedulable {
+		t.Errorf("expected a PodFailedError with reason %s, got: %v", v1.PodReasonUnschedulable, err)
+	}
+}
+
+func TestWaitForAPIError(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(testPod("error", v1.PodRunning))
+	for _, verb := range []string{"list", "get"} {
+		client.PrependReactor(verb, "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.New("the server is currently unable to handle the request")
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	err := WaitFor(ctx, client, Pod, WithNamespace(testNamespace), WithPodName("error"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v, got: %v", context.DeadlineExceeded, err)
+	}
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) || !strings.Contains(timeoutErr.Phase, "unable to handle the request") {
+		t.Errorf("expected the last API error in the phase, got: %v", err)
+	}
+}
+
+func TestWaitForPollFallback(t *testing.T) {
+	t.Parallel()
+
+	// e.g. not allowed to list and watch pods, only to get them
+	client := fake.NewSimpleClientset(testPod("fallback", v1.PodRunning))
+	client.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(v1.Resource("pods"), "", errors.New("not allowed"))
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := WaitFor(ctx, client, Pod, WithNamespace(testNamespace), WithPodName("fallback")); err != nil {
+		t.Fatalf("expected the pod to be polled, got: %v", err)
+	}
+}
+
+func TestWaitForRecordsWait(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(testPod("recorded", v1.PodPending))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	result := NewResult(NewCheck("recorded", "", nil, nil))
+
+	start := time.Now()
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		if _, err := client.CoreV1().Pods(testNamespace).UpdateStatus(ctx, testPod("recorded", v1.PodRunning), metav1.UpdateOptions{}); err != nil {
+			t.Errorf("failed to update pod: %v", err)
+		}
+	}()
+
+	if err := WaitFor(withResult(ctx, result), client, Pod, WithNamespace(testNamespace), WithPodName("recorded")); err != nil {
+		t.Fatalf("expected pod to become running, got: %v", err)
+	}
+	if len(result.Waits) != 1 {
+		t.Fatalf("expected 1 wait to be recorded, got: %v", result.Waits)
+	}
+	// watching, the change is seen right away rather than on the next poll
+	if w := result.Waits[0]; w.Resource != "pods" || w.Duration() < 200*time.Millisecond || w.Reached.Sub(start) > time.Second {
+		t.Errorf("expected the pod to be seen running shortly after 200ms, got: %+v", w)
+	}
+}
+
+func TestWaitForDeploymentAvailable(t *testing.T) {
+	t.Parallel()
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cfg.Deployment.Name,
+			Namespace: testNamespace,
+		},
+		Status: appsv1.DeploymentStatus{
+			AvailableReplicas: 1,
+		},
+	}
+	client := fake.NewSimpleClientset(deployment)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		available := deployment.DeepCopy()
+		available.Status.AvailableReplicas = 2
+		if _, err := client.AppsV1().Deployments(testNamespace).UpdateStatus(ctx, available, metav1.UpdateOptions{}); err != nil {
+			t.Errorf("failed to update deployment: %v", err)
+		}
+	}()
+
+	if err := WaitFor(ctx, client, Deployment, WithNamespace(testNamespace), WithNumReady(2)); err != nil {
+		t.Fatalf("expected deployment to become available, got: %v", err)
+	}
+}
+
+func TestWaitForNotImplemented(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, resource := range []Resource{PVC, ConfigMap, Secret} {
+		if err := WaitFor(ctx, client, resource, WithNamespace(testNamespace)); err != ErrNotImplemented {
+			t.Errorf("resource %d: expected %v, got: %v", resource, ErrNotImplemented, err)
+		}
+	}
+	if err := WaitFor(ctx, client, Resource(0), WithNamespace(testNamespace)); err != ErrUnknownResourceType {
+		t.Errorf("expected %v, got: %v", ErrUnknownResourceType, err)
+	}
+}
+
+func TestWaitForPodFailed(t *testing.T) {
+	t.Parallel()
+
+	waiting := func(reason string) v1.ContainerState {
+		return v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: reason, Message: "Back-off pulling image"}}
+	}
+
+	tests := []struct {
+		name       string
+		status     v1.PodStatus
+		wantReason string
+	}{
+		{"image pull", v1.PodStatus{Phase: v1.PodPending, ContainerStatuses: []v1.ContainerStatus{{Name: "box", State: waiting("ImagePullBackOff")}}}, "ImagePullBackOff"},
+		{"init container", v1.PodStatus{Phase: v1.PodPending, InitContainerStatuses: []v1.ContainerStatus{{Name: "init", State: waiting("ErrImagePull")}}}, "ErrImagePull"},
+		{"crash loop", v1.PodStatus{Phase: v1.PodRunning, ContainerStatuses: []v1.ContainerStatus{{Name: "box", State: waiting("CrashLoopBackOff")}}}, "CrashLoopBackOff"},
+		{"config error", v1.PodStatus{Phase: v1.PodPending, ContainerStatuses: []v1.ContainerStatus{{Name: "box", State: waiting("CreateContainerConfigError")}}}, "CreateContainerConfigError"},
+		{"terminated with error", v1.PodStatus{Phase: v1.PodFailed, ContainerStatuses: []v1.ContainerStatus{{Name: "box", State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "Error", ExitCode: 1}}}}}, "Error"},
+		{"unschedulable", v1.PodStatus{Phase: v1.PodPending, Conditions: []v1.PodCondition{{Type: v1.PodScheduled, Status: v1.ConditionFalse, Reason: v1.PodReasonUnschedulable, Message: "0/3 nodes are available"}}}, v1.PodReasonUnschedulable},
+	}
+
+	for _, tt := range tests {
+		pod := testPod("failed", tt.status.Phase)
+		pod.Status = tt.status
+		client := fake.NewSimpleClientset(pod)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		start := time.Now()
+		err := WaitFor(ctx, client, Pod, WithNamespace(testNamespace), WithPodName("failed"), WithStatus(PodCompleted))
+		cancel()
+
+		failed := &PodFailedError{}
+		if !errors.As(err, &failed) || failed.Reason != tt.wantReason {
+			t.Errorf("%s: expected a PodFailedError with reason %s, got: %v", tt.name, tt.wantReason, err)
+			continue
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Errorf("%s: expected to fail fast, took %v", tt.name, time.Since(start))
+		}
+	}
+}
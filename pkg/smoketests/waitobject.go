@@ -0,0 +1,344 @@
+// Package smoketests ... waiting for any resource, including custom resources, through the dynamic client
+package smoketests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/jpillora/backoff"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// ErrNoDynamicClient is returned by WaitForObject when there is no dynamic client, see DynamicClient
+var ErrNoDynamicClient = errors.New("no dynamic client")
+
+// dynamicKey is the context key of the dynamic client of a run
+type dynamicKey struct{}
+
+// withDynamicClient returns a copy of ctx carrying the dynamic client of the run
+func withDynamicClient(ctx context.Context, client dynamic.Interface) context.Context {
+	return context.WithValue(ctx, dynamicKey{}, client)
+}
+
+// DynamicClient returns the dynamic client of the run the check executing with ctx is part of, see
+// Runner.Dynamic, or nil
+func DynamicClient(ctx context.Context) dynamic.Interface {
+	client, _ := ctx.Value(dynamicKey{}).(dynamic.Interface)
+	return client
+}
+
+// Target selects the objects WaitForObject waits for, by name or label selector
+type Target struct {
+	// Namespace of the objects, empty for cluster-scoped objects
+	Namespace string
+	// Name selects a single object
+	Name string
+	// Selector is a label selector, all objects it selects must meet the condition, and there must be at least one
+	Selector string
+}
+
+func (t Target) String() string {
+	s := t.Name
+	if t.Namespace != "" {
+		s = t.Namespace + "/" + s
+	}
+	if t.Selector != "" {
+		s = strings.TrimSuffix(s, "/") + " selected by " + t.Selector
+	}
+	return strings.TrimSpace(s)
+}
+
+// Condition is a state WaitForObject waits for
+type Condition interface {
+	// Met returns true once obj is in the state, and describes the state seen; it returns an error if obj
+	// will never get into the state
+	Met(obj *unstructured.Unstructured) (bool, string, error)
+	// String describes the state, e.g. "Ready=True"
+	String() string
+}
+
+// statusCondition is a condition met once an object has a status.conditions entry of a type with a status
+type statusCondition struct {
+	condType, status string
+}
+
+// ConditionIs is met once an object has a condition in status.conditions of condType with status, e.g.
+// ConditionIs("Ready", "True")
+func ConditionIs(condType, status string) Condition {
+	return statusCondition{condType: condType, status: status}
+}
+
+func (c statusCondition) String() string {
+	return c.condType + "=" + c.status
+}
+
+func (c statusCondition) Met(obj *unstructured.Unstructured) (bool, string, error) {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, cond := range conditions {
+		m, ok := cond.(map[string]interface{})
+		if !ok || m["type"] != c.condType {
+			continue
+		}
+		status, _ := m["status"].(string)
+		state := c.condType + "=" + status
+		if reason, _ := m["reason"].(string); reason != "" {
+			state += ", " + reason
+		}
+		if message, _ := m["message"].(string); message != "" {
+			state += ": " + message
+		}
+		return status == c.status, state, nil
+	}
+	return false, "no " + c.condType + " condition", nil
+}
+
+// jsonPathCondition is a condition met once the value at a JSONPath of an object equals a value
+type jsonPathCondition struct {
+	path  string
+	value string
+	jp    *jsonpath.JSONPath
+	err   error
+}
+
+// JSONPathEquals is met once the value at path, e.g. {.status.phase} or .status.phase, equals value; a path
+// matching many values is met if all of them equal value
+func JSONPathEquals(path, value string) Condition {
+	if !strings.HasPrefix(path, "{") {
+		path = "{" + path + "}"
+	}
+	jp := jsonpath.New("condition").AllowMissingKeys(true)
+	err := jp.Parse(path)
+	return &jsonPathCondition{path: path, value: value, jp: jp, err: err}
+}
+
+func (c *jsonPathCondition) String() string {
+	return c.path + "=" + c.value
+}
+
+func (c *jsonPathCondition) Met(obj *unstructured.Unstructured) (bool, string, error) {
+	if c.err != nil {
+		return false, "", fmt.Errorf("invalid JSONPath %s: %v", c.path, c.err)
+	}
+	results, err := c.jp.FindResults(obj.Object)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to evaluate JSONPath %s: %v", c.path, err)
+	}
+
+	values := []string{}
+	for _, result := range results {
+		for _, v := range result {
+			values = append(values, fmt.Sprint(v.Interface()))
+		}
+	}
+	if len(values) < 1 {
+		return false, c.path + " not set", nil
+	}
+	for _, v := range values {
+		if v != c.value {
+			return false, c.path + "=" + strings.Join(values, ","), nil
+		}
+	}
+	return true, c.path + "=" + c.value, nil
+}
+
+// predicate is a condition checked by a Go function
+type predicate struct {
+	description string
+	fn          func(obj *unstructured.Unstructured) (bool, error)
+}
+
+// Predicate is met once fn returns true, fn returns an error if obj will never get into the state described
+func Predicate(description string, fn func(obj *unstructured.Unstructured) (bool, error)) Condition {
+	return predicate{description: description, fn: fn}
+}
+
+func (p predicate) String() string {
+	return p.description
+}
+
+func (p predicate) Met(obj *unstructured.Unstructured) (bool, string, error) {
+	ok, err := p.fn(obj)
+	if ok {
+		return true, p.description, err
+	}
+	return false, "not " + p.description, err
+}
+
+// conditionError is an error returned by a Condition, WaitForObject stops waiting when it sees one
+type conditionError struct {
+	err error
+}
+
+func (e *conditionError) Error() string { return e.err.Error() }
+func (e *conditionError) Unwrap() error { return e.err }
+
+// WaitForObject waits until the objects of gvr selected by target meet cond and returns nil, or a *TimeoutError
+// describing the state last seen when the ctx is done, or the error of cond. The objects are watched, so cond
+// is checked on every change; if they cannot be watched they're polled instead. The time cond was met is
+// recorded in the result of the check running with ctx, see Result.Waits.
+func WaitForObject(ctx context.Context, client dynamic.Interface, gvr schema.GroupVersionResource, target Target, cond Condition) error {
+	if client == nil {
+		return ErrNoDynamicClient
+	}
+	if target.Name == "" && target.Selector == "" {
+		return errors.New("neither name nor selector of the objects to wait for given")
+	}
+	selector, err := labels.Parse(target.Selector)
+	if err != nil {
+		return fmt.Errorf("invalid selector %q: %v", target.Selector, err)
+	}
+
+	var objects dynamic.ResourceInterface = client.Resource(gvr)
+	if target.Namespace != "" {
+		objects = client.Resource(gvr).Namespace(target.Namespace)
+	}
+	listOptions := func(opts *metav1.ListOptions) {
+		opts.LabelSelector = target.Selector
+		if target.Name != "" {
+			opts.FieldSelector = nameSelector(target.Name)
+		}
+	}
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			listOptions(&opts)
+			return objects.List(ctx, opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			listOptions(&opts)
+			return objects.Watch(ctx, opts)
+		},
+	}
+
+	phase := fmt.Sprintf("waiting for %s %s to be %s", gvr.GroupResource(), target, cond)
+	state := ""
+
+	// met checks cond for the objects in list selected by target, not all clients honor field selectors
+	met := func(list []interface{}) (bool, error) {
+		selected := []*unstructured.Unstructured{}
+		for _, item := range list {
+			obj, ok := item.(*unstructured.Unstructured)
+			if !ok || (target.Name != "" && obj.GetName() != target.Name) || !selector.Matches(labels.Set(obj.GetLabels())) {
+				continue
+			}
+			selected = append(selected, obj)
+		}
+		if len(selected) < 1 {
+			state = "not found"
+			return false, nil
+		}
+		sort.Slice(selected, func(i, j int) bool { return selected[i].GetName() < selected[j].GetName() })
+
+		for _, obj := range selected {
+			ok, s, err := cond.Met(obj)
+			if err != nil {
+				return false, &conditionError{fmt.Errorf("%s %s: %w", gvr.GroupResource(), obj.GetName(), err)}
+			}
+			if !ok {
+				state = s
+				if len(selected) > 1 {
+					state = obj.GetName() + ": " + s
+				}
+				return false, nil
+			}
+		}
+		state = ""
+		return true, nil
+	}
+
+	t := time.Now()
+	err = watchObjects(ctx, lw, met)
+	failed := &conditionError{}
+	if err != nil && ctx.Err() == nil && !errors.As(err, &failed) {
+		glog.V(2).Infof("failed to watch %s %s, polling instead: %v", gvr.GroupResource(), target, err)
+		err = pollObjects(ctx, objects, listOptions, met)
+	}
+	if err == nil {
+		reached := time.Now()
+		recordWait(ctx, Wait{Resource: gvr.GroupResource().String(), Phase: phase, Start: t, Reached: reached})
+		glog.V(2).Infof("%s took %v", strings.TrimPrefix(phase, "waiting for "), reached.Sub(t))
+		return nil
+	}
+	if errors.As(err, &failed) {
+		return failed.err
+	}
+	if ctx.Err() == nil {
+		return err
+	}
+
+	if state != "" {
+		phase += fmt.Sprintf(" (%s)", state)
+	}
+	return &TimeoutError{Phase: phase, Waited: time.Since(t), Err: ctx.Err()}
+}
+
+// watchObjects watches the objects listed by lw until met returns true
+func watchObjects(ctx context.Context, lw *cache.ListWatch, met func(list []interface{}) (bool, error)) error {
+	// fail early, rather than waiting for the cache to sync until ctx is done, e.g. when not allowed to list
+	if _, err := lw.List(metav1.ListOptions{Limit: 1}); err != nil {
+		return err
+	}
+
+	var store cache.Store
+	_, err := watchtools.UntilWithSync(ctx, lw, &unstructured.Unstructured{}, func(s cache.Store) (bool, error) {
+		store = s
+		return met(store.List())
+	}, func(event watch.Event) (bool, error) {
+		if event.Type == watch.Error {
+			return false, apierrors.FromObject(event.Object)
+		}
+		// the store is up to date with event
+		return met(store.List())
+	})
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// pollObjects lists the objects until met returns true
+func pollObjects(ctx context.Context, objects dynamic.ResourceInterface, listOptions func(opts *metav1.ListOptions), met func(list []interface{}) (bool, error)) error {
+	bo := backoff.Backoff{
+		Min:    time.Second,
+		Max:    5 * time.Second,
+		Jitter: true,
+	}
+
+	for {
+		opts := metav1.ListOptions{}
+		listOptions(&opts)
+		list, err := objects.List(ctx, opts)
+		if err != nil {
+			glog.V(2).Infof("failed to list objects: %v", err)
+		} else {
+			items := []interface{}{}
+			for i := range list.Items {
+				items = append(items, &list.Items[i])
+			}
+			ok, err := met(items)
+			if ok || err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(bo.Duration()):
+		}
+	}
+}
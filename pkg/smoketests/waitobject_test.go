@@ -0,0 +1,175 @@
+package smoketests
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// widgets are custom resources, as a check would wait for them
+var widgets = schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+
+func widget(name string, labels map[string]string, status map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": testNamespace,
+		},
+		"status": status,
+	}}
+	obj.SetLabels(labels)
+	return obj
+}
+
+func ready(status string) map[string]interface{} {
+	return map[string]interface{}{
+		"phase":      "Bound",
+		"conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": status, "reason": "Reconciling"}},
+	}
+}
+
+func TestWaitForObject(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		target Target
+		cond   Condition
+	}{
+		{"condition", Target{Namespace: testNamespace, Name: "a"}, ConditionIs("Ready", "True")},
+		{"jsonpath", Target{Namespace: testNamespace, Name: "a"}, JSONPathEquals(".status.conditions[?(@.type==\"Ready\")].status", "True")},
+		{"predicate", Target{Namespace: testNamespace, Name: "a"}, Predicate("ready", func(obj *unstructured.Unstructured) (bool, error) {
+			ok, _, _ := ConditionIs("Ready", "True").Met(obj)
+			return ok, nil
+		})},
+		{"selector", Target{Namespace: testNamespace, Selector: "app=widget"}, ConditionIs("Ready", "True")},
+	}
+
+	for _, tt := range tests {
+		client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+			widget("a", map[string]string{"app": "widget"}, ready("False")),
+			widget("b", map[string]string{"app": "widget"}, ready("False")),
+		)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		result := NewResult(NewCheck("widgets", "", nil, nil))
+
+		go func() {
+			time.Sleep(200 * time.Millisecond)
+			for _, name := range []string{"a", "b"} {
+				if _, err := client.Resource(widgets).Namespace(testNamespace).Update(ctx, widget(name, map[string]string{"app": "widget"}, ready("True")), metav1.UpdateOptions{}); err != nil {
+					t.Errorf("failed to update widget: %v", err)
+				}
+			}
+		}()
+
+		if err := WaitForObject(withResult(ctx, result), client, widgets, tt.target, tt.cond); err != nil {
+			t.Errorf("%s: expected the widgets to become ready, got: %v", tt.name, err)
+		} else if len(result.Waits) != 1 || result.Waits[0].Resource != "widgets.example.com" {
+			t.Errorf("%s: expected the wait to be recorded, got: %+v", tt.name, result.Waits)
+		}
+		cancel()
+	}
+}
+
+func TestWaitForObjectTimeout(t *testing.T) {
+	t.Parallel()
+
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+		widget("a", map[string]string{"app": "widget"}, ready("True")),
+		widget("b", map[string]string{"app": "widget"}, ready("False")),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err := WaitForObject(ctx, client, widgets, Target{Namespace: testNamespace, Selector: "app=widget"}, ConditionIs("Ready", "True"))
+	timeout := &TimeoutError{}
+	if !errors.As(err, &timeout) {
+		t.Fatalf("expected a TimeoutError, got: %v", err)
+	}
+	if want := "(b: Ready=False, Reconciling)"; !strings.HasSuffix(timeout.Phase, want) {
+		t.Errorf("expected the phase to end with %q, got: %q", want, timeout.Phase)
+	}
+}
+
+func TestWaitForObjectConditionError(t *testing.T) {
+	t.Parallel()
+
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), widget("a", nil, map[string]interface{}{"phase": "Lost"}))
+	lost := errors.New("lost")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := WaitForObject(ctx, client, widgets, Target{Namespace: testNamespace, Name: "a"}, Predicate("bound", func(obj *unstructured.Unstructured) (bool, error) {
+		phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+		if phase == "Lost" {
+			return false, lost
+		}
+		return phase == "Bound", nil
+	}))
+	if !errors.Is(err, lost) {
+		t.Fatalf("expected the predicate's error right away, got: %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Error("expected to stop waiting before the timeout")
+	}
+
+	if err := WaitForObject(ctx, client, widgets, Target{Namespace: testNamespace, Name: "a"}, JSONPathEquals("{.status[", "x")); err == nil || !strings.Contains(err.Error(), "invalid JSONPath") {
+		t.Errorf("expected an invalid JSONPath error, got: %v", err)
+	}
+	if err := WaitForObject(ctx, nil, widgets, Target{Name: "a"}, ConditionIs("Ready", "True")); !errors.Is(err, ErrNoDynamicClient) {
+		t.Errorf("expected %v, got: %v", ErrNoDynamicClient, err)
+	}
+}
+
+// dynamicFor returns a dynamic client for the objects of client, it lists and watches them as unstructured
+// objects, like the API server does for the dynamic client
+func dynamicFor(client *fake.Clientset) *dynamicfake.FakeDynamicClient {
+	dyn := dynamicfake.NewSimpleDynamicClient(scheme.Scheme)
+
+	kindFor := func(gvr schema.GroupVersionResource) schema.GroupVersionKind {
+		for gvk := range scheme.Scheme.AllKnownTypes() {
+			if plural, _ := meta.UnsafeGuessKindToResource(gvk); plural == gvr {
+				return gvk
+			}
+		}
+		return schema.GroupVersionKind{}
+	}
+
+	dyn.PrependReactor("list", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		obj, err := client.Tracker().List(action.GetResource(), kindFor(action.GetResource()), action.GetNamespace())
+		return true, obj, err
+	})
+	dyn.PrependWatchReactor("*", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		w, err := client.Tracker().Watch(action.GetResource(), action.GetNamespace())
+		if err != nil {
+			return true, nil, err
+		}
+		return true, watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+			content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(event.Object)
+			if err != nil {
+				return event, false
+			}
+			event.Object = &unstructured.Unstructured{Object: content}
+			return event, true
+		}), nil
+	})
+	return dyn
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/alex-leonhardt/kube-smoketest/pkg/metrics"
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Serve runs the smoketest suite every interval until interrupted, and exposes the results as prometheus
+// metrics on /metrics; runs never overlap, when a run takes longer than the interval the next run starts
+// as soon as it finished. Runs are isolated from each other by RunSuite: every run creates its own namespace,
+// and waits for it to be deleted before it returns; objects left behind before the daemon started fail the
+// first run with a stale state error, unless opts.Reuse or opts.Recreate
+func Serve(client kubernetes.Interface, dyn dynamic.Interface, server string, opts SuiteOptions, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := fs.String("listen", ":9090", "address to serve /metrics and /healthz on")
+	interval := fs.Duration("interval", 5*time.Minute, "time between the start of two runs")
+	timeout := fs.Duration("timeout", opts.Timeout, "max. duration of a single run, defaults to timeouts.run of the config")
+	fs.Parse(args)
+
+	if *interval <= 0 {
+		return fmt.Errorf("interval must be greater than 0, got: %v", *interval)
+	}
+	if err := ValidateTimeout("-timeout", *timeout, opts); err != nil {
+		return err
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+	m := metrics.New(reg)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", *listen, err)
+	}
+	srv := &http.Server{Handler: mux}
+	go func() {
+		if err := srv.Serve(ln); err != http.ErrServerClosed {
+			glog.Fatalf("failed to serve metrics: %v", err)
+		}
+	}()
+	glog.Infof("serving /metrics and /healthz on %s, running smoketests every %v", ln.Addr(), *interval)
+
+	ctx, stop := withSignals(context.Background(), false)
+	defer stop()
+
+	next := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			return srv.Shutdown(shutdownCtx)
+		case <-time.After(time.Until(next)):
+			// run below
+		}
+
+		start := time.Now()
+		runCtx, cancel := context.WithTimeout(ctx, *timeout)
+		report, err := RunSuite(runCtx, client, dyn, server, opts)
+		cancel()
+		if err != nil {
+			return err
+		}
+		m.Observe(report)
+		glog.Infof("run %s finished with verdict %s in %v", report.RunID, report.Verdict(), time.Since(start))
+
+		// only an earlier run of another process is stale state, what a run of this daemon failed to remove
+		// fails that run's teardown, not the next run and the daemon with it
+		if !opts.Recreate {
+			opts.Reuse = true
+		}
+
+		next = start.Add(*interval)
+		if time.Now().After(next) {
+			glog.Warningf("run %s took longer than the interval of %v, starting the next run now", report.RunID, *interval)
+			m.MissedInterval()
+			next = time.Now()
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/golang/glog"
+)
+
+// withSignals returns a copy of parent that is canceled on the first SIGINT or SIGTERM, so the checks
+// stop and everything the run created is removed; a second signal exits immediately, leaving it behind.
+// cleaning is set by the clean command, which has no run to clean up after, so the messages say so
+func withSignals(parent context.Context, cleaning bool) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	stopped := make(chan struct{})
+
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-sigCh:
+			if cleaning {
+				glog.Warningf("received %v, stopping, send it again to exit immediately", sig)
+			} else {
+				glog.Warningf("received %v, stopping and cleaning up, send it again to exit immediately", sig)
+			}
+			cancel()
+		case <-stopped:
+			return
+		}
+
+		select {
+		case sig := <-sigCh:
+			if cleaning {
+				glog.Errorf("received %v again, exiting before everything was deleted, run kube-smoketest clean again to delete what is left", sig)
+			} else {
+				glog.Errorf("received %v again, exiting without cleaning up, run kube-smoketest clean to delete what is left", sig)
+			}
+			os.Exit(130)
+		case <-stopped:
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(sigCh)
+		close(stopped)
+		cancel()
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alex-leonhardt/kube-smoketest/pkg/smoketests"
+	"github.com/golang/glog"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// SuiteOptions configure a run of the smoketest suite
+type SuiteOptions struct {
+	// Concurrency is the max. number of checks to run concurrently
+	Concurrency int
+	// Debug keeps the namespace and everything in it after the run
+	Debug bool
+	// Timeout is the max. duration of a single run
+	Timeout time.Duration
+	// CleanupTimeout is the max. duration of removing what the run created, it starts after the run ended
+	CleanupTimeout time.Duration
+	// Selection selects the checks to run, the checks they depend on are run too
+	Selection smoketests.Selection
+	// Reuse runs alongside what earlier runs left behind, instead of failing with smoketests.ErrStaleState
+	Reuse bool
+	// Recreate removes what earlier runs left behind before the run, instead of failing with smoketests.ErrStaleState
+	Recreate bool
+	// Cluster names the cluster in the log lines of the run, set by fanout to tell concurrent runs apart
+	Cluster string
+}
+
+// logPrefix starts the log lines of a run, it names the cluster if opts has one
+func (opts SuiteOptions) logPrefix() string {
+	if opts.Cluster == "" {
+		return ""
+	}
+	return fmt.Sprintf("cluster %q: ", opts.Cluster)
+}
+
+// RunSuite runs all enabled checks against the cluster client talks to at server, then removes everything
+// the run created unless debugging, even if ctx is done by then; it only returns an error if the checks
+// cannot be run at all, e.g. an earlier run left objects behind, see CheckStale
+func RunSuite(ctx context.Context, client kubernetes.Interface, dyn dynamic.Interface, server string, opts SuiteOptions) (*smoketests.Report, error) {
+	checks, err := smoketests.EnabledChecks()
+	if err != nil {
+		return nil, err
+	}
+	checks, err = smoketests.Select(checks, opts.Selection)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckStale(ctx, client, opts); err != nil {
+		return nil, err
+	}
+
+	runID := smoketests.NewRunID()
+	glog.Infof("%sstarting run %s in namespace %s", opts.logPrefix(), runID, smoketests.NamespaceName(runID))
+
+	cleanup := smoketests.NewCleanup()
+	runner := smoketests.Runner{Concurrency: opts.Concurrency, RunID: runID, Dynamic: dyn}
+	report, err := runner.Run(smoketests.WithCleanup(ctx, cleanup), client, checks)
+	if err != nil {
+		return nil, err
+	}
+	report.Cluster = smoketests.DescribeCluster(client, server)
+
+	for _, result := range report.Results {
+		LogResult(result, opts.logPrefix())
+	}
+
+	// -------------------------------------------------
+
+	if result := Teardown(client, report.Namespace, cleanup, opts); result != nil {
+		report.Add(result)
+		LogResult(result, opts.logPrefix())
+	}
+
+	return report, nil
+}
+
+// ValidateTimeout returns an error unless timeout, the run timeout set by flag, e.g. -timeout of serve, is longer
+// than any selected check and the checks it depends on may take, so a run can't time out spuriously
+func ValidateTimeout(flag string, timeout time.Duration, opts SuiteOptions) error {
+	checks, err := smoketests.EnabledChecks()
+	if err != nil {
+		return err
+	}
+	checks, err = smoketests.Select(checks, opts.Selection)
+	if err != nil {
+		return err
+	}
+	return smoketests.ValidateRunTimeout(checks, flag, timeout)
+}
+
+// CheckStale returns an error wrapping smoketests.ErrStaleState if earlier runs left objects behind, e.g. a
+// namespace kept with -debug, unless opts.Reuse runs alongside them, or opts.Recreate removes them first
+func CheckStale(ctx context.Context, client kubernetes.Interface, opts SuiteOptions) error {
+	if opts.Reuse {
+		return nil
+	}
+	stale, err := smoketests.CheckStale(ctx, client)
+	if !errors.Is(err, smoketests.ErrStaleState) || !opts.Recreate {
+		return err
+	}
+
+	glog.Infof("%sremoving %d objects earlier runs left behind", opts.logPrefix(), len(stale))
+	ctx, cancel := context.WithTimeout(ctx, opts.CleanupTimeout)
+	defer cancel()
+	return smoketests.RemoveStale(ctx, stale)
+}
+
+// Teardown removes everything tracked by cleanup, with a fresh timeout so it also happens when the run
+// timed out or was interrupted; the result lists everything that could not be removed, it's nil when there
+// is nothing to remove or debugging keeps everything, as nothing was torn down that could pass or fail
+func Teardown(client kubernetes.Interface, namespace string, cleanup *smoketests.Cleanup, opts SuiteOptions) *smoketests.Result {
+	teardown := smoketests.WithTimeout(smoketests.NewCheck(smoketests.CheckDeleteNamespace, "deletes everything the run created", []string{smoketests.TagCore}, func(ctx context.Context, _ kubernetes.Interface, _ string) error {
+		return cleanup.Run(ctx)
+	}), opts.CleanupTimeout)
+
+	objects := cleanup.Objects()
+	if len(objects) < 1 {
+		glog.V(2).Infof("nothing was created, nothing to tear down")
+		return nil
+	}
+
+	// don't delete anything when debug is set to true
+	if opts.Debug {
+		glog.Infof("\t⚠️  %s%s remain for debugging", opts.logPrefix(), strings.Join(objects, ", "))
+		return nil
+	}
+
+	return smoketests.Execute(context.Background(), client, namespace, teardown)
+}
+
+// LogResult logs a check's result, prefix starts the line, e.g. to name the cluster
+func LogResult(result *smoketests.Result, prefix string) {
+	switch result.Status {
+	case smoketests.StatusPass:
+		glog.Infof("\t✅ %s%s", prefix, result.Name)
+	case smoketests.StatusWarn:
+		glog.Warningf("\t⚠️  %s%s: %s", prefix, result.Name, strings.Join(result.Warnings, "; "))
+	case smoketests.StatusSkip:
+		glog.Warningf("\t⏭️  %s%s: %s", prefix, result.Name, result.Message)
+	default:
+		glog.Errorf("\t🔴 %s%s: %s", prefix, result.Name, result.Message)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alex-leonhardt/kube-smoketest/pkg/smoketests"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRunSuite(t *testing.T) {
+	tests := []struct {
+		name     string
+		run      string
+		debug    bool
+		teardown bool
+	}{
+		{"teardown", "^" + smoketests.CheckNamespace + "$", false, true},
+		{"debug keeps everything", "^" + smoketests.CheckNamespace + "$", true, false},
+		{"nothing created", "^" + smoketests.CheckComponentStatus + "$", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			opts := SuiteOptions{
+				Concurrency:    1,
+				Debug:          tt.debug,
+				CleanupTimeout: 10 * time.Second,
+				Selection:      smoketests.Selection{Run: regexp.MustCompile(tt.run)},
+			}
+			report, err := RunSuite(ctx, fake.NewSimpleClientset(), nil, "https://fake", opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for _, result := range report.Results {
+				if !result.Status.Passed() {
+					t.Errorf("expected %s to pass, got %s: %s", result.Name, result.Status, result.Message)
+				}
+			}
+			if !report.Passed() || report.ExitCode() != 0 {
+				t.Errorf("expected the run to pass, exit code: %d", report.ExitCode())
+			}
+			if teardown := report.Result(smoketests.CheckDeleteNamespace) != nil; teardown != tt.teardown {
+				t.Errorf("expected teardown in the report to be %v, got %v", tt.teardown, teardown)
+			}
+		})
+	}
+}
+
+func TestRunSuiteIsolated(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// like serve, one run after another against the same cluster
+	client := fake.NewSimpleClientset()
+	opts := SuiteOptions{
+		Concurrency:    1,
+		CleanupTimeout: 10 * time.Second,
+		Selection:      smoketests.Selection{Run: regexp.MustCompile("^" + smoketests.CheckNamespace + "$")},
+	}
+
+	namespaces := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		report, err := RunSuite(ctx, client, nil, "https://fake", opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !report.Passed() {
+			t.Fatalf("expected run %d to pass, exit code: %d", i, report.ExitCode())
+		}
+		if namespaces[report.Namespace] {
+			t.Fatalf("expected every run to get its own namespace, %s was used before", report.Namespace)
+		}
+		namespaces[report.Namespace] = true
+
+		if _, err := client.CoreV1().Namespaces().Get(ctx, report.Namespace, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+			t.Errorf("expected namespace %s to be gone once the run finished, got: %v", report.Namespace, err)
+		}
+	}
+}
+
+func TestRunSuiteStale(t *testing.T) {
+	// kept with -debug by an earlier run
+	leftover := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   smoketests.NamespaceName("000000000001"),
+		Labels: map[string]string{smoketests.LabelManagedBy: smoketests.ManagedBy, smoketests.LabelRunID: "000000000001"},
+	}}
+
+	tests := []struct {
+		name     string
+		reuse    bool
+		recreate bool
+		wantErr  bool
+		wantLeft bool
+	}{
+		{"strict", false, false, true, true},
+		{"reuse", true, false, false, true},
+		{"recreate", false, true, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			client := fake.NewSimpleClientset(leftover.DeepCopy())
+			opts := SuiteOptions{
+				Concurrency:    1,
+				CleanupTimeout: 10 * time.Second,
+				Selection:      smoketests.Selection{Run: regexp.MustCompile("^" + smoketests.CheckNamespace + "$")},
+				Reuse:          tt.reuse,
+				Recreate:       tt.recreate,
+			}
+			report, err := RunSuite(ctx, client, nil, "https://fake", opts)
+			if tt.wantErr {
+				if !errors.Is(err, smoketests.ErrStaleState) || !strings.Contains(err.Error(), leftover.Name) {
+					t.Fatalf("expected a stale state error naming %s, got: %v", leftover.Name, err)
+				}
+			} else if err != nil || !report.Passed() {
+				t.Fatalf("expected the run to pass, got: %v", err)
+			}
+
+			_, err = client.CoreV1().Namespaces().Get(ctx, leftover.Name, metav1.GetOptions{})
+			if left := err == nil; left != tt.wantLeft {
+				t.Errorf("expected leftover namespace %s to be left: %v, got: %v", leftover.Name, tt.wantLeft, err)
+			}
+		})
+	}
+}
+
+func TestSuiteOptionsLogPrefix(t *testing.T) {
+	if prefix := (SuiteOptions{}).logPrefix(); prefix != "" {
+		t.Errorf("expected no prefix without a cluster, got: %q", prefix)
+	}
+	if prefix := (SuiteOptions{Cluster: "prod-eu"}).logPrefix(); prefix != `cluster "prod-eu": ` {
+		t.Errorf("expected the prefix to name the cluster, got: %q", prefix)
+	}
+}
+
+func TestValidateTimeout(t *testing.T) {
+	opts := SuiteOptions{Selection: smoketests.Selection{Run: regexp.MustCompile("^" + smoketests.CheckNamespace + "$")}}
+
+	if err := ValidateTimeout("-timeout", time.Second, opts); err == nil || !strings.Contains(err.Error(), "-timeout must be greater than") {
+		t.Errorf("expected a run timeout shorter than the selected checks to be rejected, got: %v", err)
+	}
+	if err := ValidateTimeout("-timeout", 10*time.Minute, opts); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}